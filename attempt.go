@@ -0,0 +1,106 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+)
+
+// Attempt describes a single try made for a request
+type Attempt struct {
+	// Number is the zero based index of the attempt
+	Number int
+	// StatusCode is the response status, 0 when the attempt failed with Error
+	StatusCode int
+	Error      error
+	// Latency is the time the attempt took
+	Latency time.Duration
+	// Backoff is the time waited before the attempt started
+	Backoff time.Duration
+}
+
+// Attempts lists the attempts made for a request, in order
+type Attempts []Attempt
+
+// Backoff returns the total time spent waiting between attempts
+func (a Attempts) Backoff() time.Duration {
+	var backoff time.Duration
+	for _, attempt := range a {
+		backoff += attempt.Backoff
+	}
+	return backoff
+}
+
+// Text returns the attempts in the key=value format of logentry.HttpClientLogEntry.Text,
+// e.g. attempts=2,backoff_ms=3,attempt_0=status:500 cost_ms:12,attempt_1=status:200 cost_ms:10
+func (a Attempts) Text() string {
+	parts := make([]string, 0, len(a)+2)
+	parts = append(parts, fmt.Sprintf("attempts=%d", len(a)), fmt.Sprintf("backoff_ms=%d", a.Backoff().Milliseconds()))
+	for _, attempt := range a {
+		outcome := fmt.Sprintf("status:%d", attempt.StatusCode)
+		if attempt.Error != nil {
+			outcome = fmt.Sprintf("error:%v", attempt.Error)
+		}
+		parts = append(parts, fmt.Sprintf("attempt_%d=%s cost_ms:%d", attempt.Number, outcome, attempt.Latency.Milliseconds()))
+	}
+	return strings.Join(parts, ",")
+}
+
+type attemptsCtxKey struct{}
+
+// attemptRecorder collects the attempts of a single call to Client.do
+type attemptRecorder struct {
+	mu       sync.Mutex
+	attempts Attempts
+}
+
+func withAttemptRecorder(ctx context.Context) (context.Context, *attemptRecorder) {
+	recorder := &attemptRecorder{}
+	return context.WithValue(ctx, attemptsCtxKey{}, recorder), recorder
+}
+
+// CallerScoped keeps the recorder out of calls shared with other callers
+func (ar *attemptRecorder) CallerScoped() {}
+
+func (ar *attemptRecorder) record(req *http.Request, statusCode int, err error) {
+	attempt, _ := heimdall.AttemptFromContext(req.Context())
+
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+	ar.attempts = append(ar.attempts, Attempt{
+		Number:     attempt.Number,
+		StatusCode: statusCode,
+		Error:      err,
+		Latency:    attempt.Now().Sub(attempt.Start),
+		Backoff:    attempt.Backoff,
+	})
+}
+
+func (ar *attemptRecorder) Attempts() Attempts {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+	return append(Attempts(nil), ar.attempts...)
+}
+
+// attemptPlugin hands every attempt of the underlying heimdall client to the
+// attemptRecorder found in the request context
+type attemptPlugin struct{}
+
+func (attemptPlugin) OnRequestStart(*http.Request) {}
+
+func (attemptPlugin) OnRequestEnd(req *http.Request, res *http.Response) {
+	if recorder, ok := req.Context().Value(attemptsCtxKey{}).(*attemptRecorder); ok {
+		recorder.record(req, res.StatusCode, nil)
+	}
+}
+
+func (attemptPlugin) OnError(req *http.Request, err error) {
+	if recorder, ok := req.Context().Value(attemptsCtxKey{}).(*attemptRecorder); ok {
+		recorder.record(req, 0, err)
+	}
+}
@@ -8,9 +8,11 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"time"
 
 	"github.com/go-light/httpclient/v3/heimdall"
+	"github.com/go-light/httpclient/v3/heimdall/cache"
 
 	xhttpclient "github.com/go-light/httpclient/v3/heimdall/httpclient"
 	"github.com/go-light/logentry"
@@ -30,6 +32,12 @@ type myHTTPClient struct {
 }
 
 func (c *myHTTPClient) Do(request *http.Request) (*http.Response, error) {
+	if timeout, ok := request.Context().Value(timeoutCtxKey{}).(time.Duration); ok {
+		// the request overrides the client timeout, see Request.SetTimeout
+		client := c.client
+		client.Timeout = timeout
+		return client.Do(request)
+	}
 	return c.client.Do(request)
 }
 
@@ -38,6 +46,8 @@ type HttpClient interface {
 	Post(ctx context.Context, url string, body io.Reader, headers http.Header, res interface{}) (ret *Resp)
 }
 
+var _ HttpClient = (*Client)(nil)
+
 type Client struct {
 	xhttpclient *xhttpclient.Client
 	timeout     time.Duration
@@ -45,16 +55,53 @@ type Client struct {
 
 	maxIdleConns        int
 	maxIdleConnsPerHost int
+
+	connTrace   bool
+	middlewares []heimdall.Middleware
+	fallback    heimdall.FallbackFunc
+	staleCache  *cache.Stale
+	httpClient  heimdall.Doer
+	defaults    heimdall.RequestDefaults
 }
 
 type Resp struct {
 	StatusCode int
-	Body       []byte
-	Error      error
-	LogEntry   logentry.HttpClientLogEntry
+	// Header holds the response headers
+	Header   http.Header
+	Body     []byte
+	Error    error
+	LogEntry logentry.HttpClientLogEntry
+	// ConnTrace holds the connection diagnostics of the last attempt,
+	// it is only set for clients created WithConnTrace
+	ConnTrace *ConnTrace
+	// Attempts lists every attempt made for the request, retries included
+	Attempts Attempts
+	// CircuitOpen reports whether the circuit breaker rejected the request,
+	// Error then being a *CircuitOpenError
+	CircuitOpen bool
+	// Fallback reports whether the response was produced by the fallback set
+	// WithFallback, FallbackCause then holding the error it replaced
+	Fallback      bool
+	FallbackCause error
+	// Stale reports whether the response was served from the cache set
+	// WithStaleCache because the request failed, StaleAge being its age
+	Stale    bool
+	StaleAge time.Duration
 }
 
-func NewClientV3(options ...Option) HttpClient {
+// LogText returns LogEntry.Text followed by the fields the log entry has no
+// room for: circuit breaker, fallback and stale cache outcomes, attempts and, when collected, connection diagnostics
+func (r *Resp) LogText() string {
+	text := fmt.Sprintf("%s,circuit_open=%t,fallback=%t,stale=%t,%s", r.LogEntry.Text(), r.CircuitOpen, r.Fallback, r.Stale, r.Attempts.Text())
+	if r.ConnTrace != nil {
+		text += "," + r.ConnTrace.Text()
+	}
+	return text
+}
+
+// NewClientV3 returns a new client. It is returned as a *Client, which
+// implements HttpClient, so its request builder R is at hand
+func NewClientV3(options ...Option) *Client {
 	client := &Client{
 		timeout:    defaultHTTPTimeout,
 		retryCount: defaultRetryCount,
@@ -87,18 +134,26 @@ func NewClientV3(options ...Option) HttpClient {
 		ExpectContinueTimeout: 1 * time.Second,
 	}
 
-	client.xhttpclient = xhttpclient.NewClient(
-		xhttpclient.WithHTTPTimeout(client.timeout),
-		xhttpclient.WithHTTPClient(&myHTTPClient{
+	if client.httpClient == nil {
+		client.httpClient = &myHTTPClient{
 			// replace with custom HTTP client
 			client: http.Client{
-				Transport: rt,
-				Timeout:   client.timeout,
+				Transport:     rt,
+				Timeout:       client.timeout,
+				CheckRedirect: heimdall.CheckRedirect,
 			},
-		}),
+		}
+	}
+
+	client.xhttpclient = xhttpclient.NewClient(
+		xhttpclient.WithHTTPTimeout(client.timeout),
+		xhttpclient.WithKeepAlive(true),
+		xhttpclient.WithHTTPClient(client.httpClient),
 		xhttpclient.WithRetryCount(client.retryCount),
 		xhttpclient.WithRetrier(heimdall.NewRetrier(heimdall.NewConstantBackoff(1*time.Millisecond, 5*time.Millisecond))),
+		xhttpclient.WithMiddleware(client.middlewares...),
 	)
+	client.xhttpclient.AddPlugin(attemptPlugin{})
 
 	return client
 }
@@ -117,14 +172,29 @@ func (c *Client) do(ctx context.Context, url string, method string, httpHeader h
 		err           error
 		statusCode    int
 		respSizeBytes string
+		connRecorder  *heimdall.ConnRecorder
+		recorder      *attemptRecorder
 	)
 
+	ctx, recorder = withAttemptRecorder(ctx)
+	if c.connTrace {
+		connRecorder = &heimdall.ConnRecorder{}
+		ctx = httptrace.WithClientTrace(ctx, connRecorder.ClientTrace())
+	}
+
 	logEntry := logentry.NewHttpClientLogEntry(ctx)
 	logEntry.Start()
 	logEntry.SetReqUrl(url)
 	logEntry.SetMethod(method)
 
 	defer func() {
+		ret.Attempts = recorder.Attempts()
+		if connRecorder != nil {
+			ret.ConnTrace = newConnTrace(connRecorder.Events())
+			if host, _, err := net.SplitHostPort(ret.ConnTrace.RemoteAddr); err == nil {
+				logEntry.SetRemoteIP(host)
+			}
+		}
 		logEntry.SetStatusCode(statusCode)
 		logEntry.SetRespSizeBytes(respSizeBytes)
 		logEntry.End()
@@ -139,27 +209,51 @@ func (c *Client) do(ctx context.Context, url string, method string, httpHeader h
 
 	httpClient := c.xhttpclient
 
-	if httpHeader == nil {
-		httpHeader = http.Header{}
+	switch method {
+	case http.MethodGet, http.MethodPost:
+	default:
+		ret.Error = fmt.Errorf("undefined method")
+		return
 	}
 
-	contentTypes := httpHeader.Get("Content-Type")
-	if contentTypes == "" {
-		httpHeader.Add("Content-Type", "application/json; charset=utf-8")
+	// Create the request with ctx so cancellation and tracing reach every attempt
+	request, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		ret.Error = errors.Wrapf(err, "%s - request creation failed", method)
+		return
+	}
+	// the caller's headers may be shared across goroutines, they are only read
+	request.Header = httpHeader.Clone()
+	if request.Header == nil {
+		request.Header = http.Header{}
 	}
 
-	switch method {
-	case http.MethodGet:
-		// Use the clients GET method to create and execute the request
-		resp, err = httpClient.Get(url, httpHeader)
-	case http.MethodPost:
-		// Use the clients GET method to create and execute the request
-		resp, err = httpClient.Post(url, body, httpHeader)
-	default:
-		err = fmt.Errorf("undefined method")
+	request, err = c.defaults.Apply(request)
+	if err != nil {
+		ret.Error = err
 		return
 	}
+	logEntry.SetReqUrl(request.URL.String())
 
+	contentTypes := request.Header.Get("Content-Type")
+	if contentTypes == "" {
+		request.Header.Add("Content-Type", "application/json; charset=utf-8")
+	}
+
+	var fresh bool
+	if c.staleCache != nil {
+		resp, fresh = c.staleCache.Fresh(request)
+	}
+	if !fresh {
+		resp, err = httpClient.Do(request)
+		if err != nil {
+			err = requestError(err, recorder.Attempts())
+			ret.CircuitOpen = IsCircuitOpen(err)
+		}
+		resp, err = c.applyStaleCache(request, resp, err)
+	}
+
+	resp, err = c.applyFallback(request, resp, err, ret)
 	if err != nil {
 		ret.Error = err
 		return
@@ -167,6 +261,8 @@ func (c *Client) do(ctx context.Context, url string, method string, httpHeader h
 
 	statusCode = resp.StatusCode
 	ret.StatusCode = statusCode
+	ret.Header = resp.Header
+	ret.StaleAge, ret.Stale = cache.Staleness(resp)
 
 	defer resp.Body.Close()
 	respBody, err := ioutil.ReadAll(resp.Body)
@@ -193,3 +289,53 @@ func (c *Client) do(ctx context.Context, url string, method string, httpHeader h
 
 	return
 }
+
+// applyStaleCache stores the successful responses in the cache set
+// WithStaleCache, if any, and replaces failures by the stale response it
+// keeps. It runs once the retries are exhausted so they are never cut short
+func (c *Client) applyStaleCache(request *http.Request, resp *http.Response, err error) (*http.Response, error) {
+	if c.staleCache == nil {
+		return resp, err
+	}
+
+	if !c.staleCache.IsFailure(resp, err) {
+		if storeErr := c.staleCache.Store(request, resp); storeErr != nil {
+			return nil, storeErr
+		}
+		return resp, nil
+	}
+
+	stale, staleErr := c.staleCache.Fallback(request, err)
+	if staleErr != nil {
+		return resp, err
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return stale, nil
+}
+
+// applyFallback replaces a failed or 5xx response with the one of the fallback
+// set WithFallback, if any. The failure is kept when the fallback has no response to offer
+func (c *Client) applyFallback(request *http.Request, resp *http.Response, err error, ret *Resp) (*http.Response, error) {
+	if c.fallback == nil || (err == nil && resp.StatusCode < http.StatusInternalServerError) {
+		return resp, err
+	}
+
+	cause := err
+	if cause == nil {
+		cause = errors.New(resp.Status)
+	}
+
+	fallbackResp, fallbackErr := c.fallback(request, cause)
+	if fallbackErr != nil || fallbackResp == nil {
+		return resp, err
+	}
+
+	if resp != nil {
+		resp.Body.Close()
+	}
+	ret.Fallback = true
+	ret.FallbackCause = cause
+	return fallbackResp, nil
+}
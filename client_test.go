@@ -3,13 +3,23 @@ package httpclient
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/go-light/httpclient/v3/heimdall"
+	"github.com/go-light/httpclient/v3/heimdall/breaker"
+	"github.com/go-light/httpclient/v3/heimdall/cache"
+	"github.com/go-light/httpclient/v3/heimdall/fault"
+	"github.com/go-light/httpclient/v3/heimdall/heimdalltest"
+	"github.com/go-light/httpclient/v3/heimdall/limiter"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -126,3 +136,599 @@ func TestClient_Post(t *testing.T) {
 	fmt.Println(ret.LogEntry.Text())
 	fmt.Printf("%+v\n", reply)
 }
+
+func TestClient_GetWithConnTrace(t *testing.T) {
+	httpClient := NewClientV3(
+		WithTimeout(Duration(1*time.Second)),
+		WithConnTrace(),
+	)
+
+	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{}`))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(dummyHandler))
+	defer server.Close()
+
+	ret := httpClient.Get(context.Background(), server.URL, nil, nil)
+	require.NoError(t, ret.Error)
+	require.NotNil(t, ret.ConnTrace)
+	assert.Equal(t, server.Listener.Addr().String(), ret.ConnTrace.RemoteAddr)
+	assert.False(t, ret.ConnTrace.Reused)
+	assert.True(t, ret.ConnTrace.FirstByte > 0)
+	assert.Contains(t, ret.LogEntry.Text(), "remote_ip=127.0.0.1")
+	assert.Contains(t, ret.ConnTrace.Text(), "remote_addr="+server.Listener.Addr().String())
+
+	ret = httpClient.Get(context.Background(), server.URL, nil, nil)
+	require.NoError(t, ret.Error)
+	assert.True(t, ret.ConnTrace.Reused, "the second request should reuse the idle connection")
+	assert.True(t, ret.ConnTrace.WasIdle)
+}
+
+func TestClient_GetWithoutConnTrace(t *testing.T) {
+	httpClient := NewClientV3(WithTimeout(Duration(1 * time.Second)))
+
+	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(dummyHandler))
+	defer server.Close()
+
+	ret := httpClient.Get(context.Background(), server.URL, nil, nil)
+	require.NoError(t, ret.Error)
+	assert.Nil(t, ret.ConnTrace)
+}
+
+func TestClient_GetRecordsAttempts(t *testing.T) {
+	httpClient := NewClientV3(
+		WithRetryCount(2),
+		WithTimeout(Duration(1*time.Second)),
+	)
+
+	count := 0
+	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
+		count++
+		if count < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{}`))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(dummyHandler))
+	defer server.Close()
+
+	ret := httpClient.Get(context.Background(), server.URL, nil, nil)
+	require.NoError(t, ret.Error)
+
+	require.Len(t, ret.Attempts, 3)
+	for i, attempt := range ret.Attempts {
+		assert.Equal(t, i, attempt.Number)
+		assert.NoError(t, attempt.Error)
+	}
+	assert.Equal(t, http.StatusInternalServerError, ret.Attempts[0].StatusCode)
+	assert.Equal(t, http.StatusInternalServerError, ret.Attempts[1].StatusCode)
+	assert.Equal(t, http.StatusOK, ret.Attempts[2].StatusCode)
+	assert.Equal(t, time.Duration(0), ret.Attempts[0].Backoff)
+	assert.True(t, ret.Attempts.Backoff() >= 2*time.Millisecond)
+	assert.Contains(t, ret.Attempts.Text(), "attempts=3,")
+	assert.Contains(t, ret.Attempts.Text(), "attempt_2=status:200")
+}
+
+func TestClient_GetRecordsFailedAttempts(t *testing.T) {
+	httpClient := NewClientV3(WithRetryCount(1))
+
+	ret := httpClient.Get(context.Background(), "url_doesnt_exist", nil, nil)
+	require.Error(t, ret.Error)
+
+	require.Len(t, ret.Attempts, 2)
+	assert.Contains(t, ret.Attempts[1].Error.Error(), "unsupported protocol scheme")
+	assert.Contains(t, ret.Attempts.Text(), "attempt_1=error:")
+}
+
+func TestClient_GetWithCircuitBreaker(t *testing.T) {
+	httpClient := NewClientV3(
+		WithRetryCount(0),
+		WithTimeout(Duration(1*time.Second)),
+		WithCircuitBreaker(breaker.WithMinimumRequests(2)),
+	)
+
+	count := 0
+	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
+		count++
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(dummyHandler))
+	defer server.Close()
+
+	for i := 0; i < 2; i++ {
+		ret := httpClient.Get(context.Background(), server.URL, nil, nil)
+		require.Error(t, ret.Error)
+		assert.False(t, ret.CircuitOpen)
+		assert.Equal(t, http.StatusInternalServerError, ret.StatusCode)
+	}
+
+	ret := httpClient.Get(context.Background(), server.URL, nil, nil)
+	require.Error(t, ret.Error)
+	assert.True(t, ret.CircuitOpen)
+	assert.True(t, IsCircuitOpen(ret.Error))
+	assert.True(t, errors.Is(ret.Error, breaker.ErrOpen))
+	assert.Contains(t, ret.LogText(), "circuit_open=true")
+	assert.Equal(t, 2, count)
+}
+
+func TestClient_GetWithCircuitBreakerPerHost(t *testing.T) {
+	httpClient := NewClientV3(
+		WithRetryCount(0),
+		WithTimeout(Duration(1*time.Second)),
+		WithCircuitBreakerPerHost(breaker.WithMinimumRequests(1)),
+	)
+
+	failingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failingServer.Close()
+
+	healthyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthyServer.Close()
+
+	httpClient.Get(context.Background(), failingServer.URL, nil, nil)
+	ret := httpClient.Get(context.Background(), failingServer.URL, nil, nil)
+	assert.True(t, ret.CircuitOpen)
+
+	ret = httpClient.Get(context.Background(), healthyServer.URL, nil, nil)
+	require.NoError(t, ret.Error)
+	assert.False(t, ret.CircuitOpen)
+}
+
+func TestClient_GetWithBulkhead(t *testing.T) {
+	httpClient := NewClientV3(
+		WithRetryCount(0),
+		WithTimeout(Duration(1*time.Second)),
+		WithBulkhead(limiter.WithMaxConcurrent(1)),
+	)
+
+	release := make(chan struct{})
+	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(dummyHandler))
+	defer server.Close()
+
+	defer close(release)
+
+	done := make(chan *Resp)
+	go func() {
+		done <- httpClient.Get(context.Background(), server.URL, nil, nil)
+	}()
+
+	require.Eventually(t, func() bool {
+		ret := httpClient.Get(context.Background(), server.URL, nil, nil)
+		return errors.Is(ret.Error, limiter.ErrQueueFull)
+	}, time.Second, time.Millisecond)
+
+	release <- struct{}{}
+	require.NoError(t, (<-done).Error)
+}
+
+func TestClient_GetWithAdaptiveLimiter(t *testing.T) {
+	adaptive := limiter.NewAdaptiveLimiter(limiter.NewAIMD(4, 1, 10, 0.5, 0))
+	httpClient := NewClientV3(
+		WithRetryCount(0),
+		WithTimeout(Duration(1*time.Second)),
+		WithAdaptiveLimiter(adaptive),
+	)
+
+	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(dummyHandler))
+	defer server.Close()
+
+	httpClient.Get(context.Background(), server.URL, nil, nil)
+
+	assert.Equal(t, 2, adaptive.Limit())
+}
+
+func TestClient_GetWithRateLimit(t *testing.T) {
+	httpClient := NewClientV3(
+		WithRetryCount(0),
+		WithTimeout(Duration(1*time.Second)),
+		WithRateLimit(0.1, 1),
+	)
+
+	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(dummyHandler))
+	defer server.Close()
+
+	require.NoError(t, httpClient.Get(context.Background(), server.URL, nil, nil).Error)
+
+	ret := httpClient.Get(context.Background(), server.URL, nil, nil)
+	assert.True(t, errors.Is(ret.Error, limiter.ErrRateLimited))
+}
+
+func TestClient_GetWithFallback(t *testing.T) {
+	var fallbackCause error
+	httpClient := NewClientV3(
+		WithRetryCount(0),
+		WithTimeout(Duration(1*time.Second)),
+		WithFallback(func(request *http.Request, err error) (*http.Response, error) {
+			fallbackCause = err
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(strings.NewReader(`{"source":"fallback"}`)),
+			}, nil
+		}),
+	)
+
+	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(dummyHandler))
+	defer server.Close()
+
+	res := map[string]string{}
+	ret := httpClient.Get(context.Background(), server.URL, nil, &res)
+
+	require.NoError(t, ret.Error)
+	assert.True(t, ret.Fallback)
+	assert.Equal(t, http.StatusOK, ret.StatusCode)
+	assert.Equal(t, "fallback", res["source"])
+	assert.EqualError(t, ret.FallbackCause, "503 Service Unavailable")
+	assert.Equal(t, ret.FallbackCause, fallbackCause)
+	assert.Contains(t, ret.LogText(), "fallback=true")
+}
+
+func TestClient_GetWithFallbackWithoutResponse(t *testing.T) {
+	httpClient := NewClientV3(
+		WithRetryCount(0),
+		WithTimeout(Duration(1*time.Second)),
+		WithCircuitBreaker(breaker.WithMinimumRequests(1), breaker.WithErrorPercentThreshold(1)),
+		WithFallback(func(request *http.Request, err error) (*http.Response, error) {
+			return nil, err
+		}),
+	)
+
+	ret := httpClient.Get(context.Background(), "http://127.0.0.1:1", nil, nil)
+	require.Error(t, ret.Error)
+	assert.False(t, ret.Fallback)
+
+	ret = httpClient.Get(context.Background(), "http://127.0.0.1:1", nil, nil)
+	assert.True(t, IsCircuitOpen(ret.Error))
+	assert.True(t, ret.CircuitOpen)
+	assert.False(t, ret.Fallback)
+}
+
+func TestClient_GetWithStaleCache(t *testing.T) {
+	httpClient := NewClientV3(
+		WithRetryCount(0),
+		WithTimeout(Duration(1*time.Second)),
+		WithCircuitBreaker(breaker.WithMinimumRequests(1), breaker.WithErrorPercentThreshold(1)),
+		WithStaleCache(cache.WithMaxStaleAge(time.Minute)),
+	)
+
+	failing := false
+	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{"source":"downstream"}`))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(dummyHandler))
+	defer server.Close()
+
+	ret := httpClient.Get(context.Background(), server.URL, nil, nil)
+	require.NoError(t, ret.Error)
+	assert.False(t, ret.Stale)
+
+	failing = true
+	for i := 0; i < 2; i++ {
+		res := map[string]string{}
+		ret = httpClient.Get(context.Background(), server.URL, nil, &res)
+		require.NoError(t, ret.Error, "the stale response must be served on errors and while the circuit is open")
+		assert.True(t, ret.Stale)
+		assert.Equal(t, "downstream", res["source"])
+		assert.Contains(t, ret.LogText(), "stale=true")
+	}
+}
+
+func TestClient_GetWithStaleCacheRetriesFirst(t *testing.T) {
+	httpClient := NewClientV3(
+		WithRetryCount(2),
+		WithStaleCache(),
+	)
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&calls, 1) {
+		case 2:
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			fmt.Fprintf(w, `{"call":%d}`, atomic.LoadInt32(&calls))
+		}
+	}))
+	defer server.Close()
+
+	ret := httpClient.Get(context.Background(), server.URL, nil, nil)
+	require.NoError(t, ret.Error)
+
+	res := map[string]int{}
+	ret = httpClient.Get(context.Background(), server.URL, nil, &res)
+	require.NoError(t, ret.Error)
+	assert.False(t, ret.Stale, "the stale response must only be served once the retries are exhausted")
+	assert.Equal(t, 3, res["call"])
+	assert.Len(t, ret.Attempts, 2)
+}
+
+func TestClient_GetWithHTTPCache(t *testing.T) {
+	httpClient := NewClientV3(
+		WithRetryCount(0),
+		WithTimeout(Duration(1*time.Second)),
+		WithHTTPCache(),
+	)
+
+	count := 0
+	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
+		count++
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte(`{"count":1}`))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(dummyHandler))
+	defer server.Close()
+
+	for i := 0; i < 2; i++ {
+		res := map[string]int{}
+		ret := httpClient.Get(context.Background(), server.URL, nil, &res)
+		require.NoError(t, ret.Error)
+		assert.Equal(t, 1, res["count"])
+	}
+	assert.Equal(t, 1, count)
+}
+
+func TestClient_GetWithCoalescing(t *testing.T) {
+	httpClient := NewClientV3(
+		WithRetryCount(0),
+		WithTimeout(Duration(1*time.Second)),
+		WithCoalescing(),
+	)
+
+	var count int32
+	release := make(chan struct{})
+	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		<-release
+		_, _ = w.Write([]byte(`{"hot":"key"}`))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(dummyHandler))
+	defer server.Close()
+
+	const callers = 5
+	rets := make(chan *Resp, callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			rets <- httpClient.Get(context.Background(), server.URL, nil, nil)
+		}()
+	}
+
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&count) == 1 }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	var bodies [][]byte
+	for i := 0; i < callers; i++ {
+		ret := <-rets
+		require.NoError(t, ret.Error)
+		assert.Equal(t, `{"hot":"key"}`, string(ret.Body))
+		bodies = append(bodies, ret.Body)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+
+	bodies[0][0] = 'x'
+	assert.Equal(t, byte('{'), bodies[1][0], "every caller must get its own copy of the body")
+}
+
+func TestClient_GetWithCoalescingRecordsEveryCallersAttempts(t *testing.T) {
+	httpClient := NewClientV3(
+		WithRetryCount(0),
+		WithTimeout(Duration(1*time.Second)),
+		WithCoalescing(),
+	)
+
+	var count int32
+	release := make(chan struct{})
+	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		<-release
+		_, _ = w.Write([]byte(`{"hot":"key"}`))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(dummyHandler))
+	defer server.Close()
+
+	const callers = 2
+	rets := make(chan *Resp, callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			rets <- httpClient.Get(context.Background(), server.URL, nil, nil)
+		}()
+	}
+
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&count) == 1 }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	for i := 0; i < callers; i++ {
+		ret := <-rets
+		require.NoError(t, ret.Error)
+		require.Len(t, ret.Attempts, 1, "every caller must get its own attempts")
+		assert.Equal(t, http.StatusOK, ret.Attempts[0].StatusCode)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+}
+
+func TestClient_GetWithMockHTTPClient(t *testing.T) {
+	doer := heimdalltest.NewDoer()
+	doer.Expect(http.MethodGet, "http://example.com/users/1").
+		Reply(heimdalltest.Status(http.StatusServiceUnavailable)).
+		Reply(heimdalltest.Status(http.StatusOK).JSON(map[string]string{"name": "jane"}))
+
+	httpClient := NewClientV3(
+		WithRetryCount(1),
+		WithHTTPClient(doer),
+	)
+
+	res := map[string]string{}
+	ret := httpClient.Get(context.Background(), "http://example.com/users/1", nil, &res)
+	require.NoError(t, ret.Error)
+	assert.Equal(t, "jane", res["name"])
+	assert.Len(t, ret.Attempts, 2)
+	doer.AssertExpectations(t)
+}
+
+func TestClient_GetWithFaultInjector(t *testing.T) {
+	injector := fault.NewInjector(fault.WithRules(fault.Rule{Probability: 1, Fault: fault.Status(http.StatusBadGateway)}))
+	injector.Enable()
+
+	doer := heimdalltest.NewDoer()
+	doer.Expect(http.MethodGet, "http://example.com/").Reply(heimdalltest.Status(http.StatusOK))
+
+	httpClient := NewClientV3(
+		WithRetryCount(1),
+		WithHTTPClient(doer),
+		WithFaultInjector(injector),
+	)
+
+	ret := httpClient.Get(context.Background(), "http://example.com/", nil, nil)
+	assert.Equal(t, http.StatusBadGateway, ret.StatusCode)
+	assert.Len(t, ret.Attempts, 2)
+
+	injector.Disable()
+	ret = httpClient.Get(context.Background(), "http://example.com/", nil, nil)
+	require.NoError(t, ret.Error)
+}
+
+func TestClient_GetWithBaseURLAndDefaultHeaders(t *testing.T) {
+	doer := heimdalltest.NewDoer()
+	doer.Expect(http.MethodGet, "https://api.example.com/v1/users/1?fields=name").
+		WithHeader("X-Api-Key", "secret").
+		WithHeader("Accept-Language", "en").
+		Reply(heimdalltest.Status(http.StatusOK).JSON(map[string]string{"name": "jane"}))
+
+	httpClient := NewClientV3(
+		WithHTTPClient(doer),
+		WithBaseURL("https://api.example.com/v1"),
+		WithDefaultHeaders(http.Header{"X-Api-Key": {"secret"}, "Accept-Language": {"fr"}}),
+	)
+
+	res := map[string]string{}
+	ret := httpClient.Get(context.Background(), "/users/1?fields=name", http.Header{"Accept-Language": {"en"}}, &res)
+	require.NoError(t, ret.Error)
+	assert.Equal(t, "jane", res["name"])
+	assert.Contains(t, ret.LogEntry.Text(), "https://api.example.com/v1/users/1?fields=name")
+
+	ret = httpClient.R(context.Background()).
+		SetPathParam("id", "1").
+		SetQuery("fields", "name").
+		SetHeader("Accept-Language", "en").
+		Get("/users/{id}")
+	require.NoError(t, ret.Error)
+	doer.AssertExpectations(t)
+}
+
+func TestClient_GetRefusesOtherHostsWithBaseURL(t *testing.T) {
+	doer := heimdalltest.NewDoer()
+	doer.Expect(http.MethodGet, "https://cdn.example.com/logo.png").Reply(heimdalltest.Status(http.StatusOK))
+
+	httpClient := NewClientV3(
+		WithHTTPClient(doer),
+		WithBaseURL("https://api.example.com"),
+		WithAllowedHosts("cdn.example.com"),
+	)
+
+	ret := httpClient.Get(context.Background(), "https://evil.example.org/", nil, nil)
+	assert.True(t, errors.Is(ret.Error, heimdall.ErrHostNotAllowed))
+	assert.Equal(t, 0, doer.Calls())
+
+	ret = httpClient.Get(context.Background(), "https://cdn.example.com/logo.png", nil, nil)
+	assert.NoError(t, ret.Error)
+}
+
+func TestClient_ConcurrentRequestsWithSharedHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json; charset=utf-8", r.Header.Get("Content-Type"))
+		assert.Equal(t, "shared", r.Header.Get("X-Shared"))
+		w.Write([]byte(`{"name":"jane"}`))
+	}))
+	defer server.Close()
+
+	httpClient := NewClientV3(WithCoalescing())
+
+	headers := http.Header{"X-Shared": {"shared"}}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			res := map[string]string{}
+			ret := httpClient.Get(context.Background(), server.URL+"/users/1", headers, &res)
+			assert.NoError(t, ret.Error)
+		}()
+		go func() {
+			defer wg.Done()
+			ret := httpClient.Post(context.Background(), server.URL+"/users", strings.NewReader(`{}`), headers, nil)
+			assert.NoError(t, ret.Error)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, http.Header{"X-Shared": {"shared"}}, headers, "the caller's headers must not be modified")
+}
+
+func TestClient_GetRefusesRedirectsToOtherHostsWithBaseURL(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("the request must not reach another host, got X-Api-Key %q", r.Header.Get("X-Api-Key"))
+	}))
+	defer other.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved" {
+			http.Redirect(w, r, "/users", http.StatusMovedPermanently)
+			return
+		}
+		if r.URL.Path == "/users" {
+			w.Write([]byte(`{}`))
+			return
+		}
+		http.Redirect(w, r, other.URL+"/steal", http.StatusFound)
+	}))
+	defer server.Close()
+
+	httpClient := NewClientV3(
+		WithRetryCount(0),
+		WithBaseURL(server.URL),
+		WithDefaultHeaders(http.Header{"X-Api-Key": {"secret"}}),
+	)
+
+	ret := httpClient.Get(context.Background(), "/elsewhere", nil, nil)
+	assert.True(t, errors.Is(ret.Error, heimdall.ErrHostNotAllowed))
+
+	ret = httpClient.Get(context.Background(), "/moved", nil, nil)
+	assert.NoError(t, ret.Error, "redirects within the base URL host must be followed")
+}
@@ -0,0 +1,71 @@
+package httpclient
+
+import (
+	"errors"
+
+	"github.com/go-light/httpclient/v3/heimdall/breaker"
+)
+
+// CircuitOpenError is set as Resp.Error when the circuit breaker rejected the
+// request without sending it
+type CircuitOpenError struct {
+	// Err is breaker.ErrOpen or breaker.ErrTooManyProbes
+	Err error
+}
+
+func (e *CircuitOpenError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the breaker error
+func (e *CircuitOpenError) Unwrap() error {
+	return e.Err
+}
+
+// IsCircuitOpen reports whether err tells the circuit breaker rejected the request
+func IsCircuitOpen(err error) bool {
+	var circuitOpenErr *CircuitOpenError
+	return errors.As(err, &circuitOpenErr)
+}
+
+// attemptsError keeps the message of the error returned by the heimdall client,
+// which lists the errors of every attempt, while unwrapping to the error of the
+// last attempt so it can be inspected with errors.Is and errors.As
+type attemptsError struct {
+	err  error
+	last error
+}
+
+func (e *attemptsError) Error() string {
+	return e.err.Error()
+}
+
+func (e *attemptsError) Unwrap() error {
+	return e.last
+}
+
+// requestError returns the Resp.Error for err, the error returned by the
+// heimdall client after the given attempts
+func requestError(err error, attempts Attempts) error {
+	if circuitErr := circuitOpenError(attempts); circuitErr != nil {
+		return circuitErr
+	}
+	if len(attempts) == 0 || attempts[len(attempts)-1].Error == nil {
+		return err
+	}
+	return &attemptsError{err: err, last: attempts[len(attempts)-1].Error}
+}
+
+// circuitOpenError returns a CircuitOpenError when the last attempt was
+// rejected by the circuit breaker
+func circuitOpenError(attempts Attempts) error {
+	if len(attempts) == 0 {
+		return nil
+	}
+
+	err := attempts[len(attempts)-1].Error
+	if errors.Is(err, breaker.ErrOpen) || errors.Is(err, breaker.ErrTooManyProbes) {
+		return &CircuitOpenError{Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Get makes a GET request with client and decodes the JSON response body into
+// a T. Resp is always returned, for logging, and its Error is the returned error
+//
+//	user, resp, err := httpclient.Get[User](ctx, client, url, nil)
+func Get[T any](ctx context.Context, client HttpClient, url string, headers http.Header) (T, *Resp, error) {
+	var res T
+	ret := client.Get(ctx, url, headers, &res)
+	if ret.Error != nil {
+		var zero T
+		return zero, ret, ret.Error
+	}
+	return res, ret, nil
+}
+
+// Post makes a POST request with client, sending body encoded as JSON unless
+// it is an io.Reader, []byte or string, and decodes the JSON response body into a Res. Resp is
+// always returned, for logging, and its Error is the returned error
+//
+//	created, resp, err := httpclient.Post[NewUser, User](ctx, client, url, newUser, nil)
+func Post[Req, Res any](ctx context.Context, client HttpClient, url string, body Req, headers http.Header) (Res, *Resp, error) {
+	var res Res
+
+	reader, err := requestBody(body)
+	if err != nil {
+		ret := &Resp{Error: errors.Wrap(err, "POST - request body encoding failed")}
+		return res, ret, ret.Error
+	}
+
+	ret := client.Post(ctx, url, reader, headers, &res)
+	if ret.Error != nil {
+		var zero Res
+		return zero, ret, ret.Error
+	}
+	return res, ret, nil
+}
+
+// requestBody returns body as is when it is an io.Reader, []byte or string,
+// its JSON encoding otherwise
+func requestBody(body interface{}) (io.Reader, error) {
+	switch body := body.(type) {
+	case io.Reader:
+		return body, nil
+	case []byte:
+		return bytes.NewReader(body), nil
+	case string:
+		return strings.NewReader(body), nil
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
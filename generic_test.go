@@ -0,0 +1,99 @@
+package httpclient
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-light/httpclient/v3/heimdall/heimdalltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestGet(t *testing.T) {
+	doer := heimdalltest.NewDoer()
+	doer.Expect(http.MethodGet, "http://example.com/users/1").
+		Reply(heimdalltest.Status(http.StatusOK).JSON(testUser{ID: 1, Name: "jane"}))
+
+	user, ret, err := Get[testUser](context.Background(), NewClientV3(WithHTTPClient(doer)), "http://example.com/users/1", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, testUser{ID: 1, Name: "jane"}, user)
+	assert.Equal(t, http.StatusOK, ret.StatusCode)
+	assert.NotNil(t, ret.LogEntry)
+}
+
+func TestGetReturnsZeroValueOnError(t *testing.T) {
+	doer := heimdalltest.NewDoer()
+	doer.Expect(http.MethodGet, "http://example.com/users/1").
+		Reply(heimdalltest.Status(http.StatusNotFound).JSON(testUser{ID: 1}))
+
+	user, ret, err := Get[testUser](context.Background(), NewClientV3(WithRetryCount(0), WithHTTPClient(doer)), "http://example.com/users/1", nil)
+	require.Error(t, err)
+
+	assert.Equal(t, testUser{}, user)
+	assert.Equal(t, ret.Error, err)
+	assert.Equal(t, http.StatusNotFound, ret.StatusCode)
+}
+
+func TestPost(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received = string(body)
+		w.Write([]byte(`{"id":2,"name":"john"}`))
+	}))
+	defer server.Close()
+
+	user, ret, err := Post[testUser, testUser](context.Background(), NewClientV3(), server.URL, testUser{Name: "john"}, nil)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{"id":0,"name":"john"}`, received)
+	assert.Equal(t, testUser{ID: 2, Name: "john"}, user)
+	assert.Equal(t, http.StatusOK, ret.StatusCode)
+}
+
+func TestPostSendsReaderAsIs(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received = string(body)
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	_, _, err := Post[*strings.Reader, map[string]string](context.Background(), NewClientV3(), server.URL, strings.NewReader("a=1"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "a=1", received)
+}
+
+func TestPostSendsStringAndBytesAsIs(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received = append(received, string(body))
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	_, _, err := Post[string, map[string]string](context.Background(), NewClientV3(), server.URL, `{"name":"jane"}`, nil)
+	require.NoError(t, err)
+	_, _, err = Post[[]byte, map[string]string](context.Background(), NewClientV3(), server.URL, []byte("a=1"), nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{`{"name":"jane"}`, "a=1"}, received)
+}
+
+func TestPostFailsOnUnencodableBody(t *testing.T) {
+	_, ret, err := Post[chan int, testUser](context.Background(), NewClientV3(), "http://example.com", make(chan int), nil)
+	require.Error(t, err)
+	assert.Equal(t, ret.Error, err)
+}
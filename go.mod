@@ -1,19 +1,27 @@
 module github.com/go-light/httpclient/v3
 
-go 1.15
+go 1.18
 
 require (
-	github.com/DataDog/datadog-go v4.5.0+incompatible // indirect
-	github.com/Microsoft/go-winio v0.4.16 // indirect
 	github.com/afex/hystrix-go v0.0.0-20180502004556-fa1af6a1f4f5
-	github.com/cactus/go-statsd-client/statsd v0.0.0-20200423205355-cb0885a1018c // indirect
 	github.com/go-light/logentry v0.0.0-20210316084942-6667eae57844
 	github.com/gojektech/valkyrie v0.0.0-20180215180059-6aee720afcdf
+	github.com/pkg/errors v0.9.1
+	github.com/stretchr/testify v1.6.1
+)
+
+require (
+	github.com/DataDog/datadog-go v4.5.0+incompatible // indirect
+	github.com/Microsoft/go-winio v0.4.16 // indirect
+	github.com/cactus/go-statsd-client/statsd v0.0.0-20200423205355-cb0885a1018c // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/gopherjs/gopherjs v0.0.0-20181103185306-d547d1d9531e // indirect
 	github.com/kr/pretty v0.2.0 // indirect
-	github.com/pkg/errors v0.9.1
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/rcrowley/go-metrics v0.0.0-20201227073835-cf1acfcdf475 // indirect
 	github.com/smartystreets/goconvey v1.6.4 // indirect
-	github.com/stretchr/testify v1.6.1
+	github.com/stretchr/objx v0.1.1 // indirect
+	golang.org/x/sys v0.0.0-20190916202348-b4ddaad3f8a3 // indirect
 	gopkg.in/check.v1 v1.0.0-20190902080502-41f04d3bba15 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
 )
@@ -0,0 +1,74 @@
+package heimdall
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type attemptCtxKey struct{}
+
+// Attempt describes a single try of a request made by a heimdall client.
+// Clients attach it to the context of the request handed to plugins and to the
+// Doer, so it can be read back with AttemptFromContext
+type Attempt struct {
+	// Number is the zero based index of the attempt
+	Number int
+	// RequestStart is the time the first attempt of the request started
+	RequestStart time.Time
+	// Start is the time this attempt started
+	Start time.Time
+	// Backoff is the time waited after the previous attempt before this one started
+	Backoff time.Duration
+	// Clock is the clock RequestStart and Start were read from, nil meaning the
+	// system clock
+	Clock Clock
+}
+
+// Now returns the current time of the clock the attempt is timed with, so
+// durations measured from Start hold with an injected clock
+func (a Attempt) Now() time.Time {
+	if a.Clock == nil {
+		return time.Now()
+	}
+	return a.Clock.Now()
+}
+
+// ContextPlugin can optionally be implemented by a Plugin which needs to carry
+// state for the duration of an attempt, such as an httptrace.ClientTrace.
+// AttemptContext is called before every attempt and the attempt is sent with the
+// returned context, the caller's request is never modified
+type ContextPlugin interface {
+	Plugin
+	AttemptContext(ctx context.Context) context.Context
+}
+
+// CallerScoped is implemented by context values which only make sense to the
+// caller which set them, such as a recorder of its attempts. A Doer sharing a
+// single call between callers doesn't hand them over to the shared call
+type CallerScoped interface {
+	CallerScoped()
+}
+
+// WithAttempt returns a copy of ctx carrying the given attempt
+func WithAttempt(ctx context.Context, attempt Attempt) context.Context {
+	return context.WithValue(ctx, attemptCtxKey{}, attempt)
+}
+
+// AttemptFromContext returns the attempt stored in ctx, if any
+func AttemptFromContext(ctx context.Context) (Attempt, bool) {
+	attempt, ok := ctx.Value(attemptCtxKey{}).(Attempt)
+	return attempt, ok
+}
+
+// AttemptRequest returns a shallow copy of request carrying the attempt in its
+// context, after letting every ContextPlugin in plugins extend that context
+func AttemptRequest(request *http.Request, attempt Attempt, plugins []Plugin) *http.Request {
+	ctx := WithAttempt(request.Context(), attempt)
+	for _, plugin := range plugins {
+		if p, ok := plugin.(ContextPlugin); ok {
+			ctx = p.AttemptContext(ctx)
+		}
+	}
+	return request.WithContext(ctx)
+}
@@ -3,6 +3,7 @@ package heimdall
 import (
 	"math"
 	"math/rand"
+	"sync"
 	"time"
 )
 
@@ -11,9 +12,48 @@ type Backoff interface {
 	Next(retry int) time.Duration
 }
 
+// BackoffOption represents the backoff options
+type BackoffOption func(*jitter)
+
+// WithRandSource draws the jitter from src instead of the global math/rand
+// source, e.g. rand.NewSource(seed) for reproducible intervals in tests.
+// src is guarded by the backoff so it may be shared by concurrent requests
+func WithRandSource(src rand.Source) BackoffOption {
+	return func(j *jitter) {
+		j.rand = rand.New(src)
+	}
+}
+
+// jitter draws random jitter intervals from the global source, or from the
+// one set WithRandSource
+type jitter struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func newJitter(opts []BackoffOption) *jitter {
+	j := &jitter{}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j
+}
+
+// int63n returns a random number in [0,n)
+func (j *jitter) int63n(n int64) int64 {
+	if j.rand == nil {
+		return rand.Int63n(n)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.rand.Int63n(n)
+}
+
 type constantBackoff struct {
 	backoffInterval       int64
 	maximumJitterInterval int64
+	jitter                *jitter
 }
 
 func init() {
@@ -21,7 +61,7 @@ func init() {
 }
 
 // NewConstantBackoff returns an instance of ConstantBackoff
-func NewConstantBackoff(backoffInterval, maximumJitterInterval time.Duration) Backoff {
+func NewConstantBackoff(backoffInterval, maximumJitterInterval time.Duration, opts ...BackoffOption) Backoff {
 	// protect against panic when generating random jitter
 	if maximumJitterInterval < 0 {
 		maximumJitterInterval = 0
@@ -30,12 +70,13 @@ func NewConstantBackoff(backoffInterval, maximumJitterInterval time.Duration) Ba
 	return &constantBackoff{
 		backoffInterval:       int64(backoffInterval / time.Millisecond),
 		maximumJitterInterval: int64(maximumJitterInterval / time.Millisecond),
+		jitter:                newJitter(opts),
 	}
 }
 
 // Next returns next time for retrying operation with constant strategy
 func (cb *constantBackoff) Next(retry int) time.Duration {
-	return (time.Duration(cb.backoffInterval) * time.Millisecond) + (time.Duration(rand.Int63n(cb.maximumJitterInterval+1)) * time.Millisecond)
+	return (time.Duration(cb.backoffInterval) * time.Millisecond) + (time.Duration(cb.jitter.int63n(cb.maximumJitterInterval+1)) * time.Millisecond)
 }
 
 type exponentialBackoff struct {
@@ -43,10 +84,11 @@ type exponentialBackoff struct {
 	initialTimeout        float64
 	maxTimeout            float64
 	maximumJitterInterval int64
+	jitter                *jitter
 }
 
 // NewExponentialBackoff returns an instance of ExponentialBackoff
-func NewExponentialBackoff(initialTimeout, maxTimeout time.Duration, exponentFactor float64, maximumJitterInterval time.Duration) Backoff {
+func NewExponentialBackoff(initialTimeout, maxTimeout time.Duration, exponentFactor float64, maximumJitterInterval time.Duration, opts ...BackoffOption) Backoff {
 	// protect against panic when generating random jitter
 	if maximumJitterInterval < 0 {
 		maximumJitterInterval = 0
@@ -57,6 +99,7 @@ func NewExponentialBackoff(initialTimeout, maxTimeout time.Duration, exponentFac
 		initialTimeout:        float64(initialTimeout / time.Millisecond),
 		maxTimeout:            float64(maxTimeout / time.Millisecond),
 		maximumJitterInterval: int64(maximumJitterInterval / time.Millisecond),
+		jitter:                newJitter(opts),
 	}
 }
 
@@ -65,5 +108,5 @@ func (eb *exponentialBackoff) Next(retry int) time.Duration {
 	if retry < 0 {
 		retry = 0
 	}
-	return time.Duration(math.Min(eb.initialTimeout*math.Pow(eb.exponentFactor, float64(retry)), eb.maxTimeout)+float64(rand.Int63n(eb.maximumJitterInterval+1))) * time.Millisecond
+	return time.Duration(math.Min(eb.initialTimeout*math.Pow(eb.exponentFactor, float64(retry)), eb.maxTimeout)+float64(eb.jitter.int63n(eb.maximumJitterInterval+1))) * time.Millisecond
 }
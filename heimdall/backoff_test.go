@@ -1,6 +1,8 @@
 package heimdall
 
 import (
+	"math/rand"
+	"sync"
 	"testing"
 	"time"
 
@@ -108,3 +110,35 @@ func TestConstantBackoffJitter50(t *testing.T) {
 		assert.True(t, 100*time.Millisecond <= constantBackoff.Next(i) && constantBackoff.Next(1) <= 150*time.Millisecond)
 	}
 }
+
+func TestBackoffsWithSameRandSourceSeedAreReproducible(t *testing.T) {
+	first := NewExponentialBackoff(100*time.Millisecond, 1000*time.Millisecond, 2.0, 50*time.Millisecond, WithRandSource(rand.NewSource(42)))
+	second := NewExponentialBackoff(100*time.Millisecond, 1000*time.Millisecond, 2.0, 50*time.Millisecond, WithRandSource(rand.NewSource(42)))
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, first.Next(i), second.Next(i))
+	}
+
+	first = NewConstantBackoff(100*time.Millisecond, 50*time.Millisecond, WithRandSource(rand.NewSource(42)))
+	second = NewConstantBackoff(100*time.Millisecond, 50*time.Millisecond, WithRandSource(rand.NewSource(42)))
+	for i := 0; i < 100; i++ {
+		next := first.Next(i)
+		assert.Equal(t, next, second.Next(i))
+		assert.True(t, 100*time.Millisecond <= next && next <= 150*time.Millisecond)
+	}
+}
+
+func TestBackoffWithRandSourceIsSafeForConcurrentUse(t *testing.T) {
+	constantBackoff := NewConstantBackoff(100*time.Millisecond, 50*time.Millisecond, WithRandSource(rand.NewSource(1)))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				constantBackoff.Next(j)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,314 @@
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+)
+
+// State is the state of a circuit breaker
+type State int
+
+const (
+	// StateClosed lets every call through while watching the error and slow call rates
+	StateClosed State = iota
+	// StateOpen rejects every call until the open timeout elapses
+	StateOpen
+	// StateHalfOpen lets a limited number of probe calls through to decide
+	// whether to close the circuit again
+	StateHalfOpen
+)
+
+// String returns the name of the state
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("unknown state %d", int(s))
+	}
+}
+
+var (
+	// ErrOpen is returned when the circuit is open
+	ErrOpen = errors.New("circuit breaker: circuit open")
+	// ErrTooManyProbes is returned when the circuit is half-open and all probe calls are in use
+	ErrTooManyProbes = errors.New("circuit breaker: too many probe requests")
+)
+
+const (
+	defaultWindow                = 10 * time.Second
+	defaultWindowBuckets         = 10
+	defaultMinimumRequests       = 10
+	defaultErrorPercentThreshold = 50
+	defaultOpenTimeout           = 10 * time.Second
+	defaultHalfOpenMaxRequests   = 1
+)
+
+// Breaker is a circuit breaker keeping its state per instance, unlike the
+// process wide command registry of hystrix-go. It is safe for concurrent use
+type Breaker struct {
+	name                  string
+	window                time.Duration
+	windowBuckets         int
+	minimumRequests       int
+	errorPercentThreshold float64
+	slowCallDuration      time.Duration
+	slowPercentThreshold  float64
+	openTimeout           time.Duration
+	halfOpenMaxRequests   int
+	isFailure             func(*http.Response, error) bool
+	onStateChange         func(name string, from, to State)
+	clock                 heimdall.Clock
+
+	mu         sync.Mutex
+	state      State
+	generation uint64
+	openedAt   time.Time
+	buckets    []bucket
+
+	halfOpenInFlight  int
+	halfOpenSuccesses int
+}
+
+// bucket counts the calls ended during one slice of the sliding window
+type bucket struct {
+	slot     int64
+	total    int
+	failures int
+	slow     int
+}
+
+// New returns a new closed circuit breaker
+func New(opts ...Option) *Breaker {
+	b := &Breaker{
+		window:                defaultWindow,
+		windowBuckets:         defaultWindowBuckets,
+		minimumRequests:       defaultMinimumRequests,
+		errorPercentThreshold: defaultErrorPercentThreshold,
+		openTimeout:           defaultOpenTimeout,
+		halfOpenMaxRequests:   defaultHalfOpenMaxRequests,
+		isFailure:             isServerFailure,
+		clock:                 heimdall.NewSystemClock(),
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	if b.windowBuckets <= 0 {
+		b.windowBuckets = 1
+	}
+	if b.window < time.Duration(b.windowBuckets) {
+		b.window = time.Duration(b.windowBuckets)
+	}
+	if b.halfOpenMaxRequests <= 0 {
+		b.halfOpenMaxRequests = 1
+	}
+	b.buckets = make([]bucket, b.windowBuckets)
+
+	return b
+}
+
+// isServerFailure treats transport errors and 5xx responses as failures
+func isServerFailure(response *http.Response, err error) bool {
+	return err != nil || (response != nil && response.StatusCode >= http.StatusInternalServerError)
+}
+
+// Name returns the name of the breaker
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State returns the current state of the breaker
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	state, transitions := b.currentState(b.clock.Now())
+	b.mu.Unlock()
+
+	b.notify(transitions)
+	return state
+}
+
+// Allow asks the breaker for permission to make a call. On success the caller
+// must report the outcome of the call through done, the duration of the call
+// being measured from the time Allow returned
+func (b *Breaker) Allow() (done func(failed bool), err error) {
+	b.mu.Lock()
+	now := b.clock.Now()
+	state, transitions := b.currentState(now)
+
+	switch state {
+	case StateOpen:
+		err = ErrOpen
+	case StateHalfOpen:
+		if b.halfOpenInFlight+b.halfOpenSuccesses >= b.halfOpenMaxRequests {
+			err = ErrTooManyProbes
+		} else {
+			b.halfOpenInFlight++
+		}
+	}
+	generation := b.generation
+	b.mu.Unlock()
+
+	b.notify(transitions)
+	if err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	return func(failed bool) {
+		once.Do(func() {
+			b.record(generation, now, failed)
+		})
+	}, nil
+}
+
+// Wrap returns a Doer sending requests through next only while the breaker
+// allows it, so it can be used as a heimdall.Middleware
+func (b *Breaker) Wrap(next heimdall.Doer) heimdall.Doer {
+	return heimdall.DoerFunc(func(request *http.Request) (*http.Response, error) {
+		done, err := b.Allow()
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := next.Do(request)
+		done(b.isFailure(response, err))
+		return response, err
+	})
+}
+
+func (b *Breaker) record(generation uint64, start time.Time, failed bool) {
+	b.mu.Lock()
+	now := b.clock.Now()
+	state, transitions := b.currentState(now)
+	if generation != b.generation {
+		// the call started in a previous state, its outcome no longer matters
+		b.mu.Unlock()
+		b.notify(transitions)
+		return
+	}
+
+	slow := b.slowCallDuration > 0 && now.Sub(start) >= b.slowCallDuration
+
+	switch state {
+	case StateClosed:
+		current := b.bucket(now)
+		current.total++
+		if failed {
+			current.failures++
+		}
+		if slow {
+			current.slow++
+		}
+		if b.shouldTrip(now) {
+			transitions = append(transitions, b.setState(StateOpen, now))
+		}
+	case StateHalfOpen:
+		b.halfOpenInFlight--
+		if failed || slow {
+			transitions = append(transitions, b.setState(StateOpen, now))
+			break
+		}
+		b.halfOpenSuccesses++
+		if b.halfOpenSuccesses >= b.halfOpenMaxRequests {
+			transitions = append(transitions, b.setState(StateClosed, now))
+		}
+	}
+	b.mu.Unlock()
+
+	b.notify(transitions)
+}
+
+// currentState moves an open breaker to half-open once the open timeout has elapsed
+func (b *Breaker) currentState(now time.Time) (State, []transition) {
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.openTimeout {
+		return StateHalfOpen, []transition{b.setState(StateHalfOpen, now)}
+	}
+	return b.state, nil
+}
+
+type transition struct {
+	from, to State
+}
+
+func (b *Breaker) setState(state State, now time.Time) transition {
+	t := transition{from: b.state, to: state}
+
+	b.state = state
+	b.generation++
+	b.halfOpenInFlight = 0
+	b.halfOpenSuccesses = 0
+	switch state {
+	case StateOpen:
+		b.openedAt = now
+	case StateClosed:
+		for i := range b.buckets {
+			b.buckets[i] = bucket{}
+		}
+	}
+
+	return t
+}
+
+// notify runs the state change callback, outside of the lock so it may call back into the breaker
+func (b *Breaker) notify(transitions []transition) {
+	if b.onStateChange == nil {
+		return
+	}
+	for _, t := range transitions {
+		b.onStateChange(b.name, t.from, t.to)
+	}
+}
+
+func (b *Breaker) bucketWidth() int64 {
+	return int64(b.window) / int64(b.windowBuckets)
+}
+
+// bucket returns the bucket for now, resetting it if it last held an older slot
+func (b *Breaker) bucket(now time.Time) *bucket {
+	slot := now.UnixNano() / b.bucketWidth()
+	current := &b.buckets[int(slot%int64(len(b.buckets)))]
+	if current.slot != slot {
+		*current = bucket{slot: slot}
+	}
+	return current
+}
+
+// shouldTrip reports whether the calls within the sliding window exceed the
+// error or slow call thresholds
+func (b *Breaker) shouldTrip(now time.Time) bool {
+	slot := now.UnixNano() / b.bucketWidth()
+	oldest := slot - int64(len(b.buckets)) + 1
+
+	total, failures, slow := 0, 0, 0
+	for _, bkt := range b.buckets {
+		if bkt.slot < oldest || bkt.slot > slot {
+			continue
+		}
+		total += bkt.total
+		failures += bkt.failures
+		slow += bkt.slow
+	}
+
+	if total == 0 || total < b.minimumRequests {
+		return false
+	}
+	if b.errorPercentThreshold > 0 && percent(failures, total) >= b.errorPercentThreshold {
+		return true
+	}
+	return b.slowCallDuration > 0 && b.slowPercentThreshold > 0 && percent(slow, total) >= b.slowPercentThreshold
+}
+
+func percent(part, total int) float64 {
+	return float64(part) * 100 / float64(total)
+}
@@ -0,0 +1,178 @@
+package breaker
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+	"github.com/go-light/httpclient/v3/heimdall/heimdalltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFakeClock() *heimdalltest.FakeClock {
+	return heimdalltest.NewFakeClock(time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC))
+}
+
+func call(t *testing.T, b *Breaker, failed bool) {
+	done, err := b.Allow()
+	require.NoError(t, err)
+	done(failed)
+}
+
+func TestBreakerTripsOnErrorPercentage(t *testing.T) {
+	clock := newFakeClock()
+	b := New(WithClock(clock), WithMinimumRequests(4), WithErrorPercentThreshold(50))
+
+	call(t, b, false)
+	call(t, b, true)
+	call(t, b, false)
+	assert.Equal(t, StateClosed, b.State(), "should not trip below the minimum number of requests")
+
+	call(t, b, true)
+	assert.Equal(t, StateOpen, b.State())
+
+	_, err := b.Allow()
+	assert.Equal(t, ErrOpen, err)
+}
+
+func TestBreakerForgetsCallsOutsideTheWindow(t *testing.T) {
+	clock := newFakeClock()
+	b := New(WithClock(clock), WithWindow(10*time.Second, 10), WithMinimumRequests(2), WithErrorPercentThreshold(50))
+
+	call(t, b, true)
+	clock.Advance(11 * time.Second)
+	call(t, b, false)
+	call(t, b, false)
+	call(t, b, true)
+
+	assert.Equal(t, StateClosed, b.State())
+}
+
+func TestBreakerTripsOnSlowCalls(t *testing.T) {
+	clock := newFakeClock()
+	b := New(WithClock(clock), WithMinimumRequests(2), WithErrorPercentThreshold(0),
+		WithSlowCallThreshold(100*time.Millisecond, 50))
+
+	done, err := b.Allow()
+	require.NoError(t, err)
+	clock.Advance(50 * time.Millisecond)
+	done(false)
+	assert.Equal(t, StateClosed, b.State())
+
+	done, err = b.Allow()
+	require.NoError(t, err)
+	clock.Advance(150 * time.Millisecond)
+	done(false)
+	assert.Equal(t, StateOpen, b.State())
+}
+
+func TestBreakerHalfOpenProbes(t *testing.T) {
+	clock := newFakeClock()
+	b := New(WithClock(clock), WithMinimumRequests(1), WithOpenTimeout(5*time.Second), WithHalfOpenMaxRequests(2))
+
+	call(t, b, true)
+	require.Equal(t, StateOpen, b.State())
+
+	clock.Advance(5 * time.Second)
+	assert.Equal(t, StateHalfOpen, b.State())
+
+	first, err := b.Allow()
+	require.NoError(t, err)
+	second, err := b.Allow()
+	require.NoError(t, err)
+	_, err = b.Allow()
+	assert.Equal(t, ErrTooManyProbes, err)
+
+	first(false)
+	assert.Equal(t, StateHalfOpen, b.State())
+	second(false)
+	assert.Equal(t, StateClosed, b.State())
+}
+
+func TestBreakerReopensOnFailedProbe(t *testing.T) {
+	clock := newFakeClock()
+	b := New(WithClock(clock), WithMinimumRequests(1), WithOpenTimeout(5*time.Second))
+
+	call(t, b, true)
+	clock.Advance(5 * time.Second)
+	call(t, b, true)
+
+	assert.Equal(t, StateOpen, b.State())
+	clock.Advance(4 * time.Second)
+	assert.Equal(t, StateOpen, b.State())
+}
+
+func TestBreakerIgnoresOutcomesOfPreviousStates(t *testing.T) {
+	clock := newFakeClock()
+	b := New(WithClock(clock), WithMinimumRequests(1), WithOpenTimeout(5*time.Second))
+
+	late, err := b.Allow()
+	require.NoError(t, err)
+	call(t, b, true)
+	require.Equal(t, StateOpen, b.State())
+
+	clock.Advance(5 * time.Second)
+	late(false)
+	assert.Equal(t, StateHalfOpen, b.State(), "a call started while closed must not close the half-open circuit")
+}
+
+func TestBreakerReportsStateChanges(t *testing.T) {
+	clock := newFakeClock()
+	var changes []string
+	b := New(
+		WithName("payments"),
+		WithClock(clock),
+		WithMinimumRequests(1),
+		WithOpenTimeout(time.Second),
+		WithOnStateChange(func(name string, from, to State) {
+			changes = append(changes, name+": "+from.String()+" -> "+to.String())
+		}),
+	)
+
+	call(t, b, true)
+	clock.Advance(time.Second)
+	call(t, b, false)
+
+	assert.Equal(t, []string{
+		"payments: closed -> open",
+		"payments: open -> half-open",
+		"payments: half-open -> closed",
+	}, changes)
+}
+
+func TestBreakersDoNotShareState(t *testing.T) {
+	first := New(WithMinimumRequests(1))
+	second := New(WithMinimumRequests(1))
+
+	call(t, first, true)
+
+	assert.Equal(t, StateOpen, first.State())
+	assert.Equal(t, StateClosed, second.State())
+}
+
+func TestBreakerWrap(t *testing.T) {
+	b := New(WithMinimumRequests(2))
+
+	calls := 0
+	doer := b.Wrap(heimdall.DoerFunc(func(*http.Request) (*http.Response, error) {
+		calls++
+		if calls == 1 {
+			return &http.Response{StatusCode: http.StatusBadGateway}, nil
+		}
+		return nil, errors.New("connection refused")
+	}))
+
+	response, err := doer.Do(&http.Request{})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadGateway, response.StatusCode)
+
+	_, err = doer.Do(&http.Request{})
+	assert.EqualError(t, err, "connection refused")
+
+	_, err = doer.Do(&http.Request{})
+	assert.Equal(t, ErrOpen, err)
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,165 @@
+package breaker
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+)
+
+const (
+	defaultMaxBreakers = 1000
+	defaultIdleTimeout = 10 * time.Minute
+)
+
+// Group holds one Breaker per key, by default the host of the request, so a
+// failing host doesn't trip calls to healthy ones. Breakers are created lazily,
+// the group holds at most maxBreakers of them and drops those left unused for
+// longer than the idle timeout. It is safe for concurrent use
+type Group struct {
+	keyFunc        func(*http.Request) string
+	maxBreakers    int
+	idleTimeout    time.Duration
+	breakerOptions []Option
+	clock          heimdall.Clock
+
+	mu       sync.Mutex
+	breakers map[string]*list.Element
+	lru      *list.List // front is the most recently used breaker
+}
+
+type groupEntry struct {
+	key      string
+	breaker  *Breaker
+	lastUsed time.Time
+}
+
+// GroupOption represents the circuit breaker group options
+type GroupOption func(*Group)
+
+// WithKeyFunc sets how requests are mapped to breakers, by default by URL host
+func WithKeyFunc(keyFunc func(*http.Request) string) GroupOption {
+	return func(g *Group) {
+		g.keyFunc = keyFunc
+	}
+}
+
+// WithMaxBreakers bounds the number of breakers held, the least recently used is dropped first
+func WithMaxBreakers(maxBreakers int) GroupOption {
+	return func(g *Group) {
+		g.maxBreakers = maxBreakers
+	}
+}
+
+// WithIdleTimeout sets how long an unused breaker is kept, 0 keeps them until evicted by WithMaxBreakers
+func WithIdleTimeout(idleTimeout time.Duration) GroupOption {
+	return func(g *Group) {
+		g.idleTimeout = idleTimeout
+	}
+}
+
+// WithBreakerOptions sets the options every breaker of the group is created with,
+// the breaker name being set to its key
+func WithBreakerOptions(opts ...Option) GroupOption {
+	return func(g *Group) {
+		g.breakerOptions = append(g.breakerOptions, opts...)
+	}
+}
+
+// WithGroupClock sets the clock used to track idle breakers
+func WithGroupClock(clock heimdall.Clock) GroupOption {
+	return func(g *Group) {
+		g.clock = clock
+	}
+}
+
+// HostKey maps a request to the host, port included, of its URL
+func HostKey(request *http.Request) string {
+	return request.URL.Host
+}
+
+// NewGroup returns a new empty group of circuit breakers
+func NewGroup(opts ...GroupOption) *Group {
+	g := &Group{
+		keyFunc:     HostKey,
+		maxBreakers: defaultMaxBreakers,
+		idleTimeout: defaultIdleTimeout,
+		clock:       heimdall.NewSystemClock(),
+		breakers:    map[string]*list.Element{},
+		lru:         list.New(),
+	}
+
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	return g
+}
+
+// Get returns the breaker for key, creating it if needed
+func (g *Group) Get(key string) *Breaker {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.clock.Now()
+	g.evictIdle(now)
+
+	if element, ok := g.breakers[key]; ok {
+		entry := element.Value.(*groupEntry)
+		entry.lastUsed = now
+		g.lru.MoveToFront(element)
+		return entry.breaker
+	}
+
+	opts := append([]Option{WithName(key)}, g.breakerOptions...)
+	entry := &groupEntry{key: key, breaker: New(opts...), lastUsed: now}
+	g.breakers[key] = g.lru.PushFront(entry)
+
+	for g.maxBreakers > 0 && g.lru.Len() > g.maxBreakers {
+		g.remove(g.lru.Back())
+	}
+
+	return entry.breaker
+}
+
+// For returns the breaker for the key of request
+func (g *Group) For(request *http.Request) *Breaker {
+	return g.Get(g.keyFunc(request))
+}
+
+// Len returns the number of breakers held
+func (g *Group) Len() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.lru.Len()
+}
+
+// Wrap returns a Doer sending every request through the breaker of its key,
+// so it can be used as a heimdall.Middleware
+func (g *Group) Wrap(next heimdall.Doer) heimdall.Doer {
+	return heimdall.DoerFunc(func(request *http.Request) (*http.Response, error) {
+		return g.For(request).Wrap(next).Do(request)
+	})
+}
+
+// evictIdle drops breakers unused for longer than the idle timeout, starting
+// from the least recently used one
+func (g *Group) evictIdle(now time.Time) {
+	if g.idleTimeout <= 0 {
+		return
+	}
+
+	for element := g.lru.Back(); element != nil; element = g.lru.Back() {
+		if now.Sub(element.Value.(*groupEntry).lastUsed) < g.idleTimeout {
+			return
+		}
+		g.remove(element)
+	}
+}
+
+func (g *Group) remove(element *list.Element) {
+	g.lru.Remove(element)
+	delete(g.breakers, element.Value.(*groupEntry).key)
+}
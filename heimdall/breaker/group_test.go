@@ -0,0 +1,79 @@
+package breaker
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupKeepsOneBreakerPerHost(t *testing.T) {
+	g := NewGroup(WithBreakerOptions(WithMinimumRequests(1)))
+
+	doer := g.Wrap(heimdall.DoerFunc(func(request *http.Request) (*http.Response, error) {
+		if request.URL.Host == "bad.example" {
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	}))
+
+	bad, err := http.NewRequest(http.MethodGet, "http://bad.example/", nil)
+	require.NoError(t, err)
+	good, err := http.NewRequest(http.MethodGet, "http://good.example/", nil)
+	require.NoError(t, err)
+
+	_, err = doer.Do(bad)
+	assert.EqualError(t, err, "connection refused")
+	_, err = doer.Do(bad)
+	assert.Equal(t, ErrOpen, err)
+
+	response, err := doer.Do(good)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	assert.Equal(t, StateOpen, g.Get("bad.example").State())
+	assert.Equal(t, "bad.example", g.Get("bad.example").Name())
+	assert.Equal(t, StateClosed, g.Get("good.example").State())
+}
+
+func TestGroupWithKeyFunc(t *testing.T) {
+	g := NewGroup(WithKeyFunc(func(request *http.Request) string {
+		return request.Method + " " + request.URL.Path
+	}))
+
+	request, err := http.NewRequest(http.MethodPost, "http://example.com/orders", nil)
+	require.NoError(t, err)
+
+	assert.Same(t, g.Get("POST /orders"), g.For(request))
+}
+
+func TestGroupEvictsLeastRecentlyUsed(t *testing.T) {
+	g := NewGroup(WithMaxBreakers(2))
+
+	a := g.Get("a")
+	g.Get("b")
+	g.Get("a")
+	g.Get("c")
+
+	assert.Equal(t, 2, g.Len())
+	assert.Same(t, a, g.Get("a"))
+	assert.Equal(t, 2, g.Len())
+}
+
+func TestGroupEvictsIdleBreakers(t *testing.T) {
+	clock := newFakeClock()
+	g := NewGroup(WithGroupClock(clock), WithIdleTimeout(time.Minute))
+
+	a := g.Get("a")
+	clock.Advance(30 * time.Second)
+	g.Get("b")
+	clock.Advance(45 * time.Second)
+
+	g.Get("b")
+	assert.Equal(t, 1, g.Len(), "a was idle for more than a minute")
+	assert.NotSame(t, a, g.Get("a"))
+}
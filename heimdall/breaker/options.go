@@ -0,0 +1,87 @@
+package breaker
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+)
+
+// Option represents the circuit breaker options
+type Option func(*Breaker)
+
+// WithName sets the name passed to the state change callback
+func WithName(name string) Option {
+	return func(b *Breaker) {
+		b.name = name
+	}
+}
+
+// WithWindow sets the length of the sliding window the error and slow call
+// rates are computed over, and the number of buckets it is split into
+func WithWindow(window time.Duration, buckets int) Option {
+	return func(b *Breaker) {
+		b.window = window
+		b.windowBuckets = buckets
+	}
+}
+
+// WithMinimumRequests sets how many calls the window must hold before the breaker can trip
+func WithMinimumRequests(minimumRequests int) Option {
+	return func(b *Breaker) {
+		b.minimumRequests = minimumRequests
+	}
+}
+
+// WithErrorPercentThreshold sets the failure percentage at which the breaker trips, 0 disables it
+func WithErrorPercentThreshold(errorPercentThreshold float64) Option {
+	return func(b *Breaker) {
+		b.errorPercentThreshold = errorPercentThreshold
+	}
+}
+
+// WithSlowCallThreshold makes calls lasting at least duration count as slow,
+// the breaker trips once slow calls reach percentThreshold percent of the window
+func WithSlowCallThreshold(duration time.Duration, percentThreshold float64) Option {
+	return func(b *Breaker) {
+		b.slowCallDuration = duration
+		b.slowPercentThreshold = percentThreshold
+	}
+}
+
+// WithOpenTimeout sets how long the breaker stays open before letting probe calls through
+func WithOpenTimeout(openTimeout time.Duration) Option {
+	return func(b *Breaker) {
+		b.openTimeout = openTimeout
+	}
+}
+
+// WithHalfOpenMaxRequests sets how many probe calls must succeed in half-open
+// state to close the circuit, no more than that are let through at once
+func WithHalfOpenMaxRequests(halfOpenMaxRequests int) Option {
+	return func(b *Breaker) {
+		b.halfOpenMaxRequests = halfOpenMaxRequests
+	}
+}
+
+// WithFailureFunc sets how Wrap decides a call failed, by default transport
+// errors and 5xx responses are failures
+func WithFailureFunc(isFailure func(*http.Response, error) bool) Option {
+	return func(b *Breaker) {
+		b.isFailure = isFailure
+	}
+}
+
+// WithOnStateChange sets a callback run on every state change
+func WithOnStateChange(onStateChange func(name string, from, to State)) Option {
+	return func(b *Breaker) {
+		b.onStateChange = onStateChange
+	}
+}
+
+// WithClock sets the clock used to measure calls and windows
+func WithClock(clock heimdall.Clock) Option {
+	return func(b *Breaker) {
+		b.clock = clock
+	}
+}
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Entry is a response kept in a cache
+type Entry struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// StoredAt is when the response was received
+	StoredAt time.Time
+	// Vary holds the request headers named by the Vary header of the response
+	Vary http.Header
+}
+
+// Size approximates the memory held by the entry
+func (e *Entry) Size() int64 {
+	return int64(len(e.Body)) + headerSize(e.Header) + headerSize(e.Vary)
+}
+
+func headerSize(header http.Header) int64 {
+	var size int64
+	for name, values := range header {
+		size += int64(len(name))
+		for _, value := range values {
+			size += int64(len(value))
+		}
+	}
+	return size
+}
+
+// response returns a new response for request holding a copy of the entry
+func (e *Entry) response(request *http.Request) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       request,
+	}
+}
+
+// matchesVary reports whether request has the header values the entry was stored for
+func (e *Entry) matchesVary(request *http.Request) bool {
+	for name, values := range e.Vary {
+		requestValues := request.Header.Values(name)
+		if len(requestValues) != len(values) {
+			return false
+		}
+		for i := range values {
+			if requestValues[i] != values[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// refresh returns a copy of the entry updated with the headers of a 304
+// response received at now, as RFC 7234 section 4.3.4 requires
+func (e *Entry) refresh(header http.Header, now time.Time) *Entry {
+	refreshed := *e
+	refreshed.Header = e.Header.Clone()
+	refreshed.Header.Del(headerAge)
+	for name, values := range header {
+		if name == "Content-Length" {
+			continue
+		}
+		refreshed.Header[name] = append([]string(nil), values...)
+	}
+	refreshed.StoredAt = now
+	return &refreshed
+}
+
+// readEntry reads the body of response into a new entry, up to maxBytes when
+// positive. The body of response is replaced so it can still be read by the
+// caller. The entry is nil when the body is larger than maxBytes
+func readEntry(response *http.Response, maxBytes int64, now time.Time) (*Entry, error) {
+	reader := io.Reader(response.Body)
+	if maxBytes > 0 {
+		reader = io.LimitReader(response.Body, maxBytes+1)
+	}
+
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		response.Body.Close()
+		return nil, err
+	}
+
+	if maxBytes > 0 && int64(len(body)) > maxBytes {
+		response.Body = &readCloser{Reader: io.MultiReader(bytes.NewReader(body), response.Body), Closer: response.Body}
+		return nil, nil
+	}
+
+	response.Body.Close()
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return &Entry{
+		StatusCode: response.StatusCode,
+		Header:     response.Header.Clone(),
+		Body:       body,
+		StoredAt:   now,
+	}, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,331 @@
+package cache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+)
+
+const defaultHTTPCacheMaxBytes = 64 << 20
+
+// safeMethods are the methods RFC 7231 defines as safe, any other method
+// invalidates the response stored for the request URL
+var safeMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// cacheableStatusCodes are the status codes RFC 7231 defines as cacheable by default
+var cacheableStatusCodes = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+// HTTPCache is a shared RFC 7234 cache for GET requests. It serves fresh
+// responses, as told by max-age or Expires, without calling the downstream,
+// revalidates stale ones with If-None-Match and If-Modified-Since, and never
+// stores no-store or private responses, nor responses to requests carrying
+// credentials unless they are public. A single variant is kept per URL,
+// responses being matched on the request headers named by their Vary header,
+// and it is dropped by a successful unsafe request on the same URL. It is
+// safe for concurrent use
+type HTTPCache struct {
+	store    Store
+	maxBytes int64
+	clock    heimdall.Clock
+}
+
+// HTTPCacheOption represents the HTTP cache options
+type HTTPCacheOption func(*HTTPCache)
+
+// WithStore sets where responses are kept, by default in a MemoryStore
+// bounded by WithHTTPCacheMaxBytes
+func WithStore(store Store) HTTPCacheOption {
+	return func(c *HTTPCache) {
+		c.store = store
+	}
+}
+
+// WithHTTPCacheMaxBytes bounds the memory held by the default store, it also
+// bounds the size of the responses stored in a store set WithStore
+func WithHTTPCacheMaxBytes(maxBytes int64) HTTPCacheOption {
+	return func(c *HTTPCache) {
+		c.maxBytes = maxBytes
+	}
+}
+
+// WithHTTPCacheClock sets the clock used to age responses
+func WithHTTPCacheClock(clock heimdall.Clock) HTTPCacheOption {
+	return func(c *HTTPCache) {
+		c.clock = clock
+	}
+}
+
+// NewHTTPCache returns a new empty HTTP cache
+func NewHTTPCache(opts ...HTTPCacheOption) *HTTPCache {
+	c := &HTTPCache{
+		maxBytes: defaultHTTPCacheMaxBytes,
+		clock:    heimdall.NewSystemClock(),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.store == nil {
+		c.store = NewMemoryStore(c.maxBytes)
+	}
+	return c
+}
+
+// Wrap returns a Doer answering GET requests from the cache when it can, so it
+// can be used as a heimdall.Middleware
+func (c *HTTPCache) Wrap(next heimdall.Doer) heimdall.Doer {
+	return heimdall.DoerFunc(func(request *http.Request) (*http.Response, error) {
+		if !safeMethods[request.Method] {
+			return c.invalidate(next, request)
+		}
+		if request.Method != http.MethodGet || isConditional(request) {
+			return next.Do(request)
+		}
+
+		key := request.URL.String()
+		requestDirectives := parseCacheControl(request.Header)
+		if _, ok := requestDirectives["no-store"]; ok {
+			return next.Do(request)
+		}
+
+		cached, ok := c.store.Get(key)
+		if ok && !cached.matchesVary(request) {
+			cached, ok = nil, false
+		}
+
+		if ok && c.fresh(cached, requestDirectives) {
+			return c.serve(cached, request), nil
+		}
+
+		outgoing := request
+		if ok {
+			outgoing = revalidationRequest(request, cached)
+		}
+
+		response, err := next.Do(outgoing)
+		if err != nil {
+			return response, err
+		}
+
+		if ok && outgoing != request && response.StatusCode == http.StatusNotModified {
+			response.Body.Close()
+			refreshed := cached.refresh(response.Header, c.clock.Now())
+			c.store.Set(key, refreshed)
+			return c.serve(refreshed, request), nil
+		}
+
+		return c.storeResponse(key, request, response)
+	})
+}
+
+// invalidate sends an unsafe request downstream, dropping the response
+// stored for its URL once the downstream accepted it, see RFC 7234 section 4.4
+func (c *HTTPCache) invalidate(next heimdall.Doer, request *http.Request) (*http.Response, error) {
+	response, err := next.Do(request)
+	if err == nil && response.StatusCode < http.StatusBadRequest {
+		c.store.Delete(request.URL.String())
+	}
+	return response, err
+}
+
+// fresh reports whether cached can be served without revalidation
+func (c *HTTPCache) fresh(cached *Entry, requestDirectives map[string]string) bool {
+	if _, ok := requestDirectives["no-cache"]; ok {
+		return false
+	}
+	responseDirectives := parseCacheControl(cached.Header)
+	if _, ok := responseDirectives["no-cache"]; ok {
+		return false
+	}
+
+	age := c.currentAge(cached)
+	if maxAge, ok := durationDirective(requestDirectives, "max-age"); ok && age > maxAge {
+		return false
+	}
+	return age < freshnessLifetime(cached.Header, responseDirectives)
+}
+
+// currentAge returns the age of cached, taking the Age header it was received with into account
+func (c *HTTPCache) currentAge(cached *Entry) time.Duration {
+	age := c.clock.Now().Sub(cached.StoredAt)
+	if seconds, err := strconv.ParseInt(cached.Header.Get(headerAge), 10, 64); err == nil && seconds > 0 {
+		age += time.Duration(seconds) * time.Second
+	}
+	return age
+}
+
+func (c *HTTPCache) serve(cached *Entry, request *http.Request) *http.Response {
+	response := cached.response(request)
+	response.Header.Set(headerAge, strconv.FormatInt(int64(c.currentAge(cached)/time.Second), 10))
+	return response
+}
+
+// storeResponse stores response under key if it is cacheable, dropping the
+// cached entry when the response forbids storing it
+func (c *HTTPCache) storeResponse(key string, request *http.Request, response *http.Response) (*http.Response, error) {
+	directives := parseCacheControl(response.Header)
+	if _, ok := directives["no-store"]; ok {
+		c.store.Delete(key)
+		return response, nil
+	}
+	if !cacheable(request, response, directives) {
+		return response, nil
+	}
+
+	cached, err := readEntry(response, c.maxBytes, c.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil {
+		cached.Vary = varyHeader(request, response.Header)
+		c.store.Set(key, cached)
+	}
+	return response, nil
+}
+
+// cacheable reports whether response may be stored in a cache shared by
+// every caller: it must either be fresh for some time or carry a validator to
+// be revalidated with
+func cacheable(request *http.Request, response *http.Response, directives map[string]string) bool {
+	if !cacheableStatusCodes[response.StatusCode] || response.Header.Get("Vary") == "*" {
+		return false
+	}
+	if _, ok := directives["private"]; ok {
+		return false
+	}
+	if _, ok := directives["public"]; !ok && hasCredentials(request) {
+		// the response may be meant for these credentials only
+		return false
+	}
+	if freshnessLifetime(response.Header, directives) > 0 {
+		return true
+	}
+	return response.Header.Get("ETag") != "" || response.Header.Get("Last-Modified") != ""
+}
+
+// freshnessLifetime returns how long a response is fresh from max-age, or
+// from Expires and Date
+func freshnessLifetime(header http.Header, directives map[string]string) time.Duration {
+	if maxAge, ok := durationDirective(directives, "max-age"); ok {
+		return maxAge
+	}
+
+	expires := header.Get("Expires")
+	if expires == "" {
+		return 0
+	}
+	expiresAt, err := http.ParseTime(expires)
+	if err != nil {
+		// invalid dates, e.g. "0", mean already expired
+		return 0
+	}
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		return 0
+	}
+	return expiresAt.Sub(date)
+}
+
+// hasCredentials reports whether request authenticates its caller
+func hasCredentials(request *http.Request) bool {
+	return request.Header.Get("Authorization") != "" || request.Header.Get("Cookie") != ""
+}
+
+// isConditional reports whether the caller made the request conditional
+// itself, the response then being handed over untouched
+func isConditional(request *http.Request) bool {
+	return request.Header.Get("If-None-Match") != "" || request.Header.Get("If-Modified-Since") != ""
+}
+
+// revalidationRequest returns a copy of request made conditional on the
+// validators of cached
+func revalidationRequest(request *http.Request, cached *Entry) *http.Request {
+	etag := cached.Header.Get("ETag")
+	lastModified := cached.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return request
+	}
+
+	conditional := request.Clone(request.Context())
+	if etag != "" {
+		conditional.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		conditional.Header.Set("If-Modified-Since", lastModified)
+	}
+	return conditional
+}
+
+// varyHeader returns the request headers named by the Vary header of a response
+func varyHeader(request *http.Request, header http.Header) http.Header {
+	names := headerList(header, "Vary")
+	if len(names) == 0 {
+		return nil
+	}
+
+	vary := http.Header{}
+	for _, name := range names {
+		vary[http.CanonicalHeaderKey(name)] = request.Header.Values(name)
+	}
+	return vary
+}
+
+// parseCacheControl returns the directives of the Cache-Control header, keyed
+// by lower case name
+func parseCacheControl(header http.Header) map[string]string {
+	directives := map[string]string{}
+	for _, directive := range headerList(header, "Cache-Control") {
+		name, value := directive, ""
+		if i := strings.IndexByte(directive, '='); i >= 0 {
+			name, value = directive[:i], strings.Trim(directive[i+1:], `"`)
+		}
+		directives[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+	}
+	return directives
+}
+
+// durationDirective returns the value of a delta-seconds directive
+func durationDirective(directives map[string]string, name string) (time.Duration, bool) {
+	value, ok := directives[name]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// headerList returns the comma separated elements of the values of a header
+func headerList(header http.Header, name string) []string {
+	var list []string
+	for _, value := range header.Values(name) {
+		for _, element := range strings.Split(value, ",") {
+			if element = strings.TrimSpace(element); element != "" {
+				list = append(list, element)
+			}
+		}
+	}
+	return list
+}
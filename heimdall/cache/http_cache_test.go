@@ -0,0 +1,310 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall/heimdalltest"
+	"github.com/go-light/httpclient/v3/heimdall/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCachingClient(c *HTTPCache) *httpclient.Client {
+	return httpclient.NewClient(httpclient.WithMiddleware(c.Wrap))
+}
+
+func TestHTTPCacheServesFreshResponses(t *testing.T) {
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte("fresh"))
+	}))
+	defer server.Close()
+
+	clock := heimdalltest.NewFakeClock(time.Now())
+	client := newCachingClient(NewHTTPCache(WithHTTPCacheClock(clock)))
+
+	response, err := client.Get(server.URL, http.Header{})
+	require.NoError(t, err)
+	assert.Equal(t, "fresh", heimdalltest.ResponseBody(t, response))
+
+	clock.Advance(30 * time.Second)
+	response, err = client.Get(server.URL, http.Header{})
+	require.NoError(t, err)
+	assert.Equal(t, "fresh", heimdalltest.ResponseBody(t, response))
+	assert.Equal(t, "30", response.Header.Get("Age"))
+	assert.Equal(t, 1, count)
+
+	clock.Advance(30 * time.Second)
+	response, err = client.Get(server.URL, http.Header{})
+	require.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, 2, count, "expired responses must not be served")
+}
+
+func TestHTTPCacheHonoursRequestNoCache(t *testing.T) {
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		w.Header().Set("Cache-Control", "max-age=60")
+	}))
+	defer server.Close()
+
+	client := newCachingClient(NewHTTPCache())
+
+	response, err := client.Get(server.URL, http.Header{})
+	require.NoError(t, err)
+	response.Body.Close()
+
+	response, err = client.Get(server.URL, http.Header{"Cache-Control": {"no-cache"}})
+	require.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, 2, count)
+}
+
+func TestHTTPCacheDoesNotStoreNoStoreResponses(t *testing.T) {
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		w.Header().Set("Cache-Control", "no-store, max-age=60")
+	}))
+	defer server.Close()
+
+	store := NewMemoryStore(0)
+	client := newCachingClient(NewHTTPCache(WithStore(store)))
+
+	for i := 0; i < 2; i++ {
+		response, err := client.Get(server.URL, http.Header{})
+		require.NoError(t, err)
+		response.Body.Close()
+	}
+	assert.Equal(t, 2, count)
+	assert.Equal(t, 0, store.Len())
+}
+
+func TestHTTPCacheRevalidatesWithETag(t *testing.T) {
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "no-cache")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte("body v1"))
+	}))
+	defer server.Close()
+
+	client := newCachingClient(NewHTTPCache())
+
+	for i := 0; i < 2; i++ {
+		response, err := client.Get(server.URL, http.Header{})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Equal(t, "body v1", heimdalltest.ResponseBody(t, response))
+	}
+	assert.Equal(t, 2, count)
+}
+
+func TestHTTPCacheRevalidatesWithLastModified(t *testing.T) {
+	lastModified := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	var conditions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditions = append(conditions, r.Header.Get("If-Modified-Since"))
+		w.Header().Set("Last-Modified", lastModified)
+		if r.Header.Get("If-Modified-Since") == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte("body"))
+	}))
+	defer server.Close()
+
+	client := newCachingClient(NewHTTPCache())
+
+	for i := 0; i < 2; i++ {
+		response, err := client.Get(server.URL, http.Header{})
+		require.NoError(t, err)
+		assert.Equal(t, "body", heimdalltest.ResponseBody(t, response))
+	}
+	assert.Equal(t, []string{"", lastModified}, conditions)
+}
+
+func TestHTTPCachePassesCallerConditionalRequestsThrough(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte("body"))
+	}))
+	defer server.Close()
+
+	client := newCachingClient(NewHTTPCache())
+
+	response, err := client.Get(server.URL, http.Header{"If-None-Match": {`"v1"`}})
+	require.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusNotModified, response.StatusCode)
+}
+
+func TestHTTPCacheMatchesVary(t *testing.T) {
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		_, _ = w.Write([]byte("hello " + r.Header.Get("Accept-Language")))
+	}))
+	defer server.Close()
+
+	client := newCachingClient(NewHTTPCache())
+
+	get := func(language string) string {
+		response, err := client.Get(server.URL, http.Header{"Accept-Language": {language}})
+		require.NoError(t, err)
+		return heimdalltest.ResponseBody(t, response)
+	}
+
+	assert.Equal(t, "hello en", get("en"))
+	assert.Equal(t, "hello en", get("en"))
+	assert.Equal(t, "hello fr", get("fr"))
+	assert.Equal(t, 2, count)
+}
+
+func TestHTTPCacheUsesExpires(t *testing.T) {
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		now := time.Now().UTC()
+		w.Header().Set("Date", now.Format(http.TimeFormat))
+		w.Header().Set("Expires", now.Add(time.Minute).Format(http.TimeFormat))
+	}))
+	defer server.Close()
+
+	client := newCachingClient(NewHTTPCache())
+
+	for i := 0; i < 2; i++ {
+		response, err := client.Get(server.URL, http.Header{})
+		require.NoError(t, err)
+		response.Body.Close()
+	}
+	assert.Equal(t, 1, count)
+}
+
+func TestHTTPCacheDoesNotShareCredentialedResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+
+	client := newCachingClient(NewHTTPCache())
+
+	response, err := client.Get(server.URL, http.Header{"Authorization": {"Bearer alice"}})
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer alice", heimdalltest.ResponseBody(t, response))
+
+	response, err = client.Get(server.URL, http.Header{})
+	require.NoError(t, err)
+	assert.Empty(t, heimdalltest.ResponseBody(t, response), "a response to other credentials must not be served")
+}
+
+func TestHTTPCacheStoresPublicCredentialedResponses(t *testing.T) {
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		w.Header().Set("Cache-Control", "public, max-age=60")
+	}))
+	defer server.Close()
+
+	client := newCachingClient(NewHTTPCache())
+
+	for _, header := range []http.Header{{"Cookie": {"session=alice"}}, {}} {
+		response, err := client.Get(server.URL, header)
+		require.NoError(t, err)
+		response.Body.Close()
+	}
+	assert.Equal(t, 1, count)
+}
+
+func TestHTTPCacheDoesNotStorePrivateResponses(t *testing.T) {
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		w.Header().Set("Cache-Control", "private, max-age=60")
+	}))
+	defer server.Close()
+
+	client := newCachingClient(NewHTTPCache())
+
+	for i := 0; i < 2; i++ {
+		response, err := client.Get(server.URL, http.Header{})
+		require.NoError(t, err)
+		response.Body.Close()
+	}
+	assert.Equal(t, 2, count)
+}
+
+func TestHTTPCacheInvalidatesOnUnsafeMethods(t *testing.T) {
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			count++
+		}
+		w.Header().Set("Cache-Control", "max-age=60")
+	}))
+	defer server.Close()
+
+	client := newCachingClient(NewHTTPCache())
+
+	get := func() {
+		response, err := client.Get(server.URL, http.Header{})
+		require.NoError(t, err)
+		response.Body.Close()
+	}
+
+	get()
+	get()
+	assert.Equal(t, 1, count)
+
+	response, err := client.Post(server.URL, strings.NewReader("{}"), http.Header{})
+	require.NoError(t, err)
+	response.Body.Close()
+
+	get()
+	assert.Equal(t, 2, count, "a POST must drop the stored response")
+
+	response, err = client.Delete(server.URL, http.Header{})
+	require.NoError(t, err)
+	response.Body.Close()
+
+	get()
+	assert.Equal(t, 3, count, "a DELETE must drop the stored response")
+}
+
+func TestMemoryStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	store := NewMemoryStore(10)
+	store.Set("a", &Entry{Body: []byte("aaaa")})
+	store.Set("b", &Entry{Body: []byte("bbbb")})
+	_, _ = store.Get("a")
+	store.Set("c", &Entry{Body: []byte("cccc")})
+
+	_, ok := store.Get("b")
+	assert.False(t, ok)
+	_, ok = store.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, int64(8), store.Bytes())
+
+	store.Set("d", &Entry{Body: []byte("too large to be stored")})
+	_, ok = store.Get("d")
+	assert.False(t, ok)
+}
@@ -0,0 +1,263 @@
+package cache
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+)
+
+const (
+	defaultMaxStaleAge   = time.Hour
+	defaultStaleMaxBytes = 32 << 20
+
+	headerAge     = "Age"
+	headerWarning = "Warning"
+
+	// staleWarning is the RFC 7234 warning set on stale responses, its agent
+	// tells it from the warnings of upstream caches
+	staleWarning = `110 heimdall "Response is Stale"`
+)
+
+// ErrNoStaleResponse is returned by Stale.Fallback when no response can be served
+var ErrNoStaleResponse = errors.New("cache: no stale response")
+
+// Stale keeps the last successful response per key and serves it when the
+// downstream fails, with an Age header and a 110 Warning header set so callers
+// can tell it is stale, see Staleness. It is safe for concurrent use
+type Stale struct {
+	ttl         time.Duration
+	maxStaleAge time.Duration
+	maxBytes    int64
+	keyFunc     func(*http.Request) string
+	isFailure   func(*http.Response, error) bool
+	clock       heimdall.Clock
+
+	store Store
+}
+
+// StaleOption represents the stale cache options
+type StaleOption func(*Stale)
+
+// WithTTL sets how long a response is fresh, fresh responses being served
+// without calling the downstream. 0, the default, always calls the downstream
+func WithTTL(ttl time.Duration) StaleOption {
+	return func(s *Stale) {
+		s.ttl = ttl
+	}
+}
+
+// WithMaxStaleAge sets the age of the oldest response served on failure, 0 means no limit
+func WithMaxStaleAge(maxStaleAge time.Duration) StaleOption {
+	return func(s *Stale) {
+		s.maxStaleAge = maxStaleAge
+	}
+}
+
+// WithMaxBytes bounds the memory held by the cached responses, the least
+// recently used are dropped first. It also bounds the size of the responses
+// stored in a store set WithStaleStore
+func WithMaxBytes(maxBytes int64) StaleOption {
+	return func(s *Stale) {
+		s.maxBytes = maxBytes
+	}
+}
+
+// WithStaleStore sets where responses are kept, by default in a MemoryStore
+// bounded by WithMaxBytes
+func WithStaleStore(store Store) StaleOption {
+	return func(s *Stale) {
+		s.store = store
+	}
+}
+
+// WithStaleKeyFunc sets how requests are mapped to cache keys, an empty key
+// meaning the request must not be cached. By default GET and HEAD requests
+// are cached by method, URL and heimdall.DefaultKeyHeaders, so a response is
+// never served to a caller with other credentials
+func WithStaleKeyFunc(keyFunc func(*http.Request) string) StaleOption {
+	return func(s *Stale) {
+		s.keyFunc = keyFunc
+	}
+}
+
+// WithStaleFailureFunc sets how Wrap decides the downstream failed, by
+// default transport errors and 5xx responses are failures
+func WithStaleFailureFunc(isFailure func(*http.Response, error) bool) StaleOption {
+	return func(s *Stale) {
+		s.isFailure = isFailure
+	}
+}
+
+// WithStaleClock sets the clock used to age responses
+func WithStaleClock(clock heimdall.Clock) StaleOption {
+	return func(s *Stale) {
+		s.clock = clock
+	}
+}
+
+// NewStale returns a new empty stale cache
+func NewStale(opts ...StaleOption) *Stale {
+	s := &Stale{
+		maxStaleAge: defaultMaxStaleAge,
+		maxBytes:    defaultStaleMaxBytes,
+		keyFunc:     MethodURLHeadersKey(heimdall.DefaultKeyHeaders...),
+		isFailure:   isServerFailure,
+		clock:       heimdall.NewSystemClock(),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.store == nil {
+		s.store = NewMemoryStore(s.maxBytes)
+	}
+	return s
+}
+
+// MethodURLKey maps GET and HEAD requests to their method and URL, other
+// requests are not cached
+func MethodURLKey(request *http.Request) string {
+	if request.Method != http.MethodGet && request.Method != http.MethodHead {
+		return ""
+	}
+	return request.Method + " " + request.URL.String()
+}
+
+// MethodURLHeadersKey returns a key func mapping GET and HEAD requests to their
+// method, URL and the values of the given headers, other requests are not cached
+func MethodURLHeadersKey(headers ...string) func(*http.Request) string {
+	return func(request *http.Request) string {
+		if request.Method != http.MethodGet && request.Method != http.MethodHead {
+			return ""
+		}
+		return heimdall.RequestKey(request, headers)
+	}
+}
+
+func isServerFailure(response *http.Response, err error) bool {
+	return err != nil || (response != nil && response.StatusCode >= http.StatusInternalServerError)
+}
+
+// Wrap returns a Doer storing the successful responses of next and serving
+// them instead of failures, so it can be used as a heimdall.Middleware. It
+// must wrap the circuit breaker, if any, to serve requests rejected by it, and
+// the retries, if any, so they are not cut short by a stale response
+func (s *Stale) Wrap(next heimdall.Doer) heimdall.Doer {
+	return heimdall.DoerFunc(func(request *http.Request) (*http.Response, error) {
+		if fresh, ok := s.Fresh(request); ok {
+			return fresh, nil
+		}
+
+		response, err := next.Do(request)
+		if s.IsFailure(response, err) {
+			if stale, staleErr := s.Fallback(request, err); staleErr == nil {
+				if response != nil {
+					response.Body.Close()
+				}
+				return stale, nil
+			}
+			return response, err
+		}
+
+		if err := s.Store(request, response); err != nil {
+			return nil, err
+		}
+		return response, nil
+	})
+}
+
+// Fresh returns the response of request stored less than the TTL set WithTTL ago, if any
+func (s *Stale) Fresh(request *http.Request) (*http.Response, bool) {
+	if s.ttl <= 0 {
+		return nil, false
+	}
+
+	key := s.keyFunc(request)
+	if key == "" {
+		return nil, false
+	}
+	if cached, ok := s.store.Get(key); ok && s.age(cached) < s.ttl {
+		return s.serve(cached, request, false), true
+	}
+	return nil, false
+}
+
+// IsFailure reports whether response and err tell the downstream failed, see WithStaleFailureFunc
+func (s *Stale) IsFailure(response *http.Response, err error) bool {
+	return s.isFailure(response, err)
+}
+
+// Store keeps response, when it is a 2xx, as the stale response of request.
+// The body of response is replaced so it can still be read by the caller
+func (s *Stale) Store(request *http.Request, response *http.Response) error {
+	key := s.keyFunc(request)
+	if key == "" || response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return nil
+	}
+
+	cached, err := readEntry(response, s.maxBytes, s.clock.Now())
+	if err != nil {
+		return err
+	}
+	if cached != nil {
+		s.store.Set(key, cached)
+	}
+	return nil
+}
+
+// Fallback serves the stale response of request, so it can be used as a heimdall.FallbackFunc
+func (s *Stale) Fallback(request *http.Request, err error) (*http.Response, error) {
+	if key := s.keyFunc(request); key != "" {
+		if stale, ok := s.staleResponse(key, request); ok {
+			return stale, nil
+		}
+	}
+	return nil, ErrNoStaleResponse
+}
+
+func (s *Stale) staleResponse(key string, request *http.Request) (*http.Response, bool) {
+	cached, ok := s.store.Get(key)
+	if !ok {
+		return nil, false
+	}
+	if s.maxStaleAge > 0 && s.age(cached) > s.maxStaleAge {
+		s.store.Delete(key)
+		return nil, false
+	}
+	return s.serve(cached, request, true), true
+}
+
+func (s *Stale) age(cached *Entry) time.Duration {
+	return s.clock.Now().Sub(cached.StoredAt)
+}
+
+func (s *Stale) serve(cached *Entry, request *http.Request, stale bool) *http.Response {
+	response := cached.response(request)
+	response.Header.Set(headerAge, strconv.FormatInt(int64(s.age(cached)/time.Second), 10))
+	if stale {
+		response.Header.Add(headerWarning, staleWarning)
+	}
+	return response
+}
+
+// Staleness reports whether response was served stale by a Stale cache, and
+// its age as told by its Age header. Stale warnings set by upstream caches are ignored
+func Staleness(response *http.Response) (age time.Duration, stale bool) {
+	if response == nil {
+		return 0, false
+	}
+
+	for _, warning := range response.Header.Values(headerWarning) {
+		if warning == staleWarning {
+			stale = true
+		}
+	}
+	if seconds, err := strconv.ParseInt(response.Header.Get(headerAge), 10, 64); err == nil {
+		age = time.Duration(seconds) * time.Second
+	}
+	return age, stale
+}
@@ -0,0 +1,207 @@
+package cache
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+	"github.com/go-light/httpclient/v3/heimdall/heimdalltest"
+	"github.com/go-light/httpclient/v3/heimdall/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyServer answers with the given status codes in turn, the body being the
+// number of the request
+func flakyServer(statuses ...int) (*httptest.Server, *int) {
+	count := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := statuses[count%len(statuses)]
+		count++
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("response " + string(rune('0'+count))))
+	})), &count
+}
+
+func TestStaleServesLastSuccessOnFailure(t *testing.T) {
+	server, _ := flakyServer(http.StatusOK, http.StatusInternalServerError)
+	defer server.Close()
+
+	clock := heimdalltest.NewFakeClock(time.Now())
+	stale := NewStale(WithStaleClock(clock))
+	client := httpclient.NewClient(httpclient.WithMiddleware(stale.Wrap))
+
+	response, err := client.Get(server.URL, http.Header{})
+	require.NoError(t, err)
+	assert.Equal(t, "response 1", heimdalltest.ResponseBody(t, response))
+	_, isStale := Staleness(response)
+	assert.False(t, isStale)
+
+	clock.Advance(3 * time.Second)
+	response, err = client.Get(server.URL, http.Header{})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "response 1", heimdalltest.ResponseBody(t, response))
+
+	age, isStale := Staleness(response)
+	assert.True(t, isStale)
+	assert.Equal(t, 3*time.Second, age)
+}
+
+func TestStaleDoesNotServeResponsesOlderThanMaxStaleAge(t *testing.T) {
+	server, _ := flakyServer(http.StatusOK, http.StatusInternalServerError)
+	defer server.Close()
+
+	clock := heimdalltest.NewFakeClock(time.Now())
+	store := NewMemoryStore(0)
+	stale := NewStale(WithStaleClock(clock), WithMaxStaleAge(time.Minute), WithStaleStore(store))
+	client := httpclient.NewClient(httpclient.WithMiddleware(stale.Wrap))
+
+	response, err := client.Get(server.URL, http.Header{})
+	require.NoError(t, err)
+	response.Body.Close()
+
+	clock.Advance(2 * time.Minute)
+	response, err = client.Get(server.URL, http.Header{})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
+	assert.Equal(t, "response 2", heimdalltest.ResponseBody(t, response))
+	assert.Equal(t, 0, store.Len(), "expired responses must be dropped")
+}
+
+func TestStaleServesFreshResponsesWithinTTL(t *testing.T) {
+	server, count := flakyServer(http.StatusOK)
+	defer server.Close()
+
+	clock := heimdalltest.NewFakeClock(time.Now())
+	stale := NewStale(WithStaleClock(clock), WithTTL(time.Minute))
+	client := httpclient.NewClient(httpclient.WithMiddleware(stale.Wrap))
+
+	for i := 0; i < 2; i++ {
+		response, err := client.Get(server.URL, http.Header{})
+		require.NoError(t, err)
+		assert.Equal(t, "response 1", heimdalltest.ResponseBody(t, response))
+	}
+	assert.Equal(t, 1, *count)
+
+	clock.Advance(time.Minute)
+	response, err := client.Get(server.URL, http.Header{})
+	require.NoError(t, err)
+	assert.Equal(t, "response 2", heimdalltest.ResponseBody(t, response))
+}
+
+func TestStaleServesRequestsRejectedByInnerMiddlewares(t *testing.T) {
+	failing := false
+	stale := NewStale()
+	doer := heimdall.Chain(heimdall.DoerFunc(func(request *http.Request) (*http.Response, error) {
+		if failing {
+			return nil, errors.New("circuit open")
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader("ok"))}, nil
+	}), stale.Wrap)
+
+	request, err := http.NewRequest(http.MethodGet, "http://example.com/a", nil)
+	require.NoError(t, err)
+
+	response, err := doer.Do(request)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", heimdalltest.ResponseBody(t, response))
+
+	failing = true
+	response, err = doer.Do(request)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", heimdalltest.ResponseBody(t, response))
+
+	post, err := http.NewRequest(http.MethodPost, "http://example.com/a", nil)
+	require.NoError(t, err)
+	_, err = doer.Do(post)
+	assert.EqualError(t, err, "circuit open", "only GET and HEAD requests are cached by default")
+}
+
+func TestStaleFallback(t *testing.T) {
+	stale := NewStale()
+	request, err := http.NewRequest(http.MethodGet, "http://example.com/a", nil)
+	require.NoError(t, err)
+
+	_, err = stale.Fallback(request, errors.New("boom"))
+	assert.Equal(t, ErrNoStaleResponse, err)
+
+	doer := stale.Wrap(heimdall.DoerFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader("ok"))}, nil
+	}))
+	response, err := doer.Do(request)
+	require.NoError(t, err)
+	response.Body.Close()
+
+	response, err = stale.Fallback(request, errors.New("boom"))
+	require.NoError(t, err)
+	assert.Equal(t, "ok", heimdalltest.ResponseBody(t, response))
+}
+
+func TestStaleBoundsMemory(t *testing.T) {
+	body := strings.Repeat("x", 40)
+	store := NewMemoryStore(100)
+	stale := NewStale(WithMaxBytes(100), WithStaleStore(store))
+	doer := stale.Wrap(heimdall.DoerFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}))
+
+	for _, path := range []string{"/a", "/b", "/c"} {
+		request, err := http.NewRequest(http.MethodGet, "http://example.com"+path, nil)
+		require.NoError(t, err)
+		response, err := doer.Do(request)
+		require.NoError(t, err)
+		assert.Equal(t, body, heimdalltest.ResponseBody(t, response))
+	}
+	assert.Equal(t, int64(80), store.Bytes())
+
+	request, err := http.NewRequest(http.MethodGet, "http://example.com/a", nil)
+	require.NoError(t, err)
+	_, err = stale.Fallback(request, nil)
+	assert.Equal(t, ErrNoStaleResponse, err, "the least recently used response must have been dropped")
+
+	body = strings.Repeat("y", 200)
+	request, err = http.NewRequest(http.MethodGet, "http://example.com/big", nil)
+	require.NoError(t, err)
+	response, err := doer.Do(request)
+	require.NoError(t, err)
+	assert.Equal(t, body, heimdalltest.ResponseBody(t, response), "bodies larger than the cache must be passed through whole")
+	assert.Equal(t, int64(80), store.Bytes())
+}
+
+func TestStaleIsNotServedToOtherCredentials(t *testing.T) {
+	server, _ := flakyServer(http.StatusOK, http.StatusInternalServerError, http.StatusInternalServerError)
+	defer server.Close()
+
+	stale := NewStale()
+	client := httpclient.NewClient(httpclient.WithMiddleware(stale.Wrap))
+
+	response, err := client.Get(server.URL, http.Header{"Authorization": {"Bearer alice"}})
+	require.NoError(t, err)
+	heimdalltest.ResponseBody(t, response)
+
+	response, err = client.Get(server.URL, http.Header{"Authorization": {"Bearer bob"}})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, response.StatusCode, "alice's response must not be served to bob")
+	heimdalltest.ResponseBody(t, response)
+
+	response, err = client.Get(server.URL, http.Header{"Authorization": {"Bearer alice"}})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "response 1", heimdalltest.ResponseBody(t, response))
+}
+
+func TestStalenessIgnoresUpstreamWarnings(t *testing.T) {
+	response := &http.Response{Header: http.Header{}}
+	response.Header.Set("Age", "30")
+	response.Header.Add("Warning", `110 proxy.example.com "Response is Stale"`)
+
+	age, isStale := Staleness(response)
+	assert.False(t, isStale, "only the warnings of this cache make a response stale")
+	assert.Equal(t, 30*time.Second, age)
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Store holds the cached responses. Implementations must be safe for
+// concurrent use, and may keep entries in an external cache, e.g. Redis
+type Store interface {
+	// Get returns the entry of key, if any
+	Get(key string) (*Entry, bool)
+	// Set stores entry under key
+	Set(key string, entry *Entry)
+	// Delete drops the entry of key, if any
+	Delete(key string)
+}
+
+// MemoryStore is an in memory Store holding entries up to a total size in
+// bytes, dropping the least recently used ones first
+type MemoryStore struct {
+	maxBytes int64
+
+	mu      sync.Mutex
+	size    int64
+	entries map[string]*list.Element
+	order   *list.List // front is the most recently used entry
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+type memoryItem struct {
+	key   string
+	entry *Entry
+}
+
+// NewMemoryStore returns a new empty store holding up to maxBytes, 0 means no limit
+func NewMemoryStore(maxBytes int64) *MemoryStore {
+	return &MemoryStore{
+		maxBytes: maxBytes,
+		entries:  map[string]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+// Get returns the entry of key, if any
+func (s *MemoryStore) Get(key string) (*Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	s.order.MoveToFront(element)
+	return element.Value.(*memoryItem).entry, true
+}
+
+// Set stores entry under key, unless it is larger than the whole store
+func (s *MemoryStore) Set(key string, entry *Entry) {
+	size := entry.Size()
+	if s.maxBytes > 0 && size > s.maxBytes {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		s.removeElement(element)
+	}
+	s.entries[key] = s.order.PushFront(&memoryItem{key: key, entry: entry})
+	s.size += size
+
+	for s.maxBytes > 0 && s.size > s.maxBytes {
+		s.removeElement(s.order.Back())
+	}
+}
+
+// Delete drops the entry of key, if any
+func (s *MemoryStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		s.removeElement(element)
+	}
+}
+
+// Bytes returns the total size of the entries held
+func (s *MemoryStore) Bytes() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// Len returns the number of entries held
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+func (s *MemoryStore) removeElement(element *list.Element) {
+	item := element.Value.(*memoryItem)
+	s.order.Remove(element)
+	delete(s.entries, item.key)
+	s.size -= item.entry.Size()
+}
@@ -0,0 +1,54 @@
+package heimdall
+
+import (
+	"context"
+	"time"
+)
+
+// Clock tells the current time. Components taking a Clock can be driven by a
+// fake one in tests instead of waiting on the wall clock
+type Clock interface {
+	Now() time.Time
+}
+
+// Sleeper waits, for a backoff or a rate limit token. Sleep returns ctx.Err()
+// when ctx is done before d elapsed
+type Sleeper interface {
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+type systemClock struct{}
+
+// NewSystemClock returns a Clock backed by time.Now
+func NewSystemClock() Clock {
+	return systemClock{}
+}
+
+// Now returns the current local time
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+type systemSleeper struct{}
+
+// NewSystemSleeper returns a Sleeper backed by a time.Timer
+func NewSystemSleeper() Sleeper {
+	return systemSleeper{}
+}
+
+// Sleep waits for d unless ctx is done first
+func (systemSleeper) Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
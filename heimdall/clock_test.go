@@ -0,0 +1,28 @@
+package heimdall
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSystemSleeperSleeps(t *testing.T) {
+	start := time.Now()
+	err := NewSystemSleeper().Sleep(context.Background(), 5*time.Millisecond)
+
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) >= 5*time.Millisecond)
+}
+
+func TestSystemSleeperStopsWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := NewSystemSleeper().Sleep(ctx, time.Minute)
+
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.True(t, time.Since(start) < time.Minute)
+}
@@ -0,0 +1,194 @@
+// Package coalesce shares a single upstream call between identical
+// idempotent requests in flight at the same time
+package coalesce
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+)
+
+// Group coalesces identical requests, those with the same method, URL and key
+// headers, in flight at the same time into one call whose response is copied
+// to every caller. It is safe for concurrent use
+type Group struct {
+	keyHeaders []string
+	methods    map[string]bool
+
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// call is an upstream call shared by the callers of one key
+type call struct {
+	done     chan struct{}
+	response *http.Response // its body has been read into body
+	body     []byte
+	err      error
+	callers  int
+	cancel   context.CancelFunc
+}
+
+// Option represents the coalescing group options
+type Option func(*Group)
+
+// WithKeyHeaders sets the request headers which must match, along with the
+// method and URL, for requests to be coalesced. By default Authorization,
+// Cookie and Accept
+func WithKeyHeaders(names ...string) Option {
+	return func(g *Group) {
+		g.keyHeaders = names
+	}
+}
+
+// WithMethods sets the methods of the requests which may be coalesced, by
+// default GET and HEAD. Only idempotent methods should be given
+func WithMethods(methods ...string) Option {
+	return func(g *Group) {
+		g.methods = map[string]bool{}
+		for _, method := range methods {
+			g.methods[method] = true
+		}
+	}
+}
+
+// New returns a new coalescing group
+func New(opts ...Option) *Group {
+	g := &Group{
+		keyHeaders: heimdall.DefaultKeyHeaders,
+		methods:    map[string]bool{http.MethodGet: true, http.MethodHead: true},
+		calls:      map[string]*call{},
+	}
+
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	return g
+}
+
+// InFlight returns the number of upstream calls in flight
+func (g *Group) InFlight() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.calls)
+}
+
+// Wrap returns a Doer coalescing identical requests sent through next, so it
+// can be used as a heimdall.Middleware. The shared call doesn't stop when the
+// caller which started it gives up, only once every caller has, each caller
+// returning as soon as its own context is done
+func (g *Group) Wrap(next heimdall.Doer) heimdall.Doer {
+	return heimdall.DoerFunc(func(request *http.Request) (*http.Response, error) {
+		if !g.methods[request.Method] || request.Body != nil && request.Body != http.NoBody {
+			return next.Do(request)
+		}
+
+		key := g.key(request)
+		ctx := request.Context()
+
+		g.mu.Lock()
+		c, ok := g.calls[key]
+		if !ok {
+			callCtx, cancel := context.WithCancel(detach(ctx))
+			c = &call{done: make(chan struct{}), cancel: cancel}
+			g.calls[key] = c
+
+			go g.run(key, c, next, request.WithContext(callCtx))
+		}
+		c.callers++
+		g.mu.Unlock()
+
+		select {
+		case <-c.done:
+			return c.copy(request)
+		case <-ctx.Done():
+			g.leave(key, c)
+			return nil, ctx.Err()
+		}
+	})
+}
+
+func (g *Group) run(key string, c *call, next heimdall.Doer, request *http.Request) {
+	defer c.cancel()
+
+	response, err := next.Do(request)
+	if err == nil {
+		c.body, err = ioutil.ReadAll(response.Body)
+		response.Body.Close()
+	}
+	c.response, c.err = response, err
+
+	g.mu.Lock()
+	if g.calls[key] == c {
+		delete(g.calls, key)
+	}
+	g.mu.Unlock()
+	close(c.done)
+}
+
+// leave drops a caller which gave up, the call being canceled once all have
+// so that new callers start a fresh one
+func (g *Group) leave(key string, c *call) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	c.callers--
+	if c.callers == 0 {
+		if g.calls[key] == c {
+			delete(g.calls, key)
+		}
+		c.cancel()
+	}
+}
+
+func (g *Group) key(request *http.Request) string {
+	return heimdall.RequestKey(request, g.keyHeaders)
+}
+
+// copy returns a response of its own to a caller of the call
+func (c *call) copy(request *http.Request) (*http.Response, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	response := *c.response
+	response.Header = c.response.Header.Clone()
+	response.Body = ioutil.NopCloser(bytes.NewReader(c.body))
+	response.ContentLength = int64(len(c.body))
+	response.Request = request
+	return &response, nil
+}
+
+// detachedContext keeps the values of its parent but is never canceled with
+// it. The values belonging to the caller which started the call, its Attempt,
+// its httptrace.ClientTrace and any heimdall.CallerScoped value, are dropped
+// since the call is shared by every caller
+type detachedContext struct {
+	parent context.Context
+}
+
+func detach(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	switch value := c.parent.Value(key); value.(type) {
+	case heimdall.Attempt, *httptrace.ClientTrace, heimdall.CallerScoped:
+		return nil
+	default:
+		return value
+	}
+}
@@ -0,0 +1,176 @@
+package coalesce
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+	"github.com/go-light/httpclient/v3/heimdall/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupCoalescesIdenticalRequests(t *testing.T) {
+	var count int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		<-release
+		w.Header().Set("X-Shared", "yes")
+		_, _ = w.Write([]byte("hot"))
+	}))
+	defer server.Close()
+
+	g := New()
+	client := httpclient.NewClient(httpclient.WithMiddleware(g.Wrap))
+
+	const callers = 10
+	var wg sync.WaitGroup
+	bodies := make(chan string, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := client.Get(server.URL, http.Header{})
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer response.Body.Close()
+			assert.Equal(t, "yes", response.Header.Get("X-Shared"))
+			response.Header.Set("X-Shared", "mutated")
+
+			body, err := ioutil.ReadAll(response.Body)
+			assert.NoError(t, err)
+			bodies <- string(body)
+		}()
+	}
+
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&count) == 1 }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(bodies)
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+	for body := range bodies {
+		assert.Equal(t, "hot", body)
+	}
+	assert.Equal(t, 0, g.InFlight())
+}
+
+func TestGroupKeepsRequestsWithDifferentKeyHeadersApart(t *testing.T) {
+	g := New(WithKeyHeaders("Authorization"))
+
+	var mu sync.Mutex
+	seen := map[string]bool{}
+	release := make(chan struct{})
+	doer := g.Wrap(heimdall.DoerFunc(func(request *http.Request) (*http.Response, error) {
+		mu.Lock()
+		seen[request.Header.Get("Authorization")] = true
+		mu.Unlock()
+		<-release
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody}, nil
+	}))
+
+	var wg sync.WaitGroup
+	for _, token := range []string{"a", "b"} {
+		request, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		require.NoError(t, err)
+		request.Header.Set("Authorization", token)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = doer.Do(request)
+		}()
+	}
+
+	require.Eventually(t, func() bool { return g.InFlight() == 2 }, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, map[string]bool{"a": true, "b": true}, seen)
+}
+
+func TestGroupDoesNotCoalesceOtherMethods(t *testing.T) {
+	g := New()
+	calls := 0
+	doer := g.Wrap(heimdall.DoerFunc(func(request *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	}))
+
+	request, err := http.NewRequest(http.MethodPost, "http://example.com", nil)
+	require.NoError(t, err)
+	_, err = doer.Do(request)
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 0, g.InFlight())
+}
+
+func TestGroupCallerHonoursItsContext(t *testing.T) {
+	g := New()
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	doer := g.Wrap(heimdall.DoerFunc(func(request *http.Request) (*http.Response, error) {
+		close(started)
+		<-request.Context().Done()
+		close(canceled)
+		return nil, request.Context().Err()
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+
+	go func() {
+		<-started
+		cancel()
+	}()
+	_, err = doer.Do(request)
+	assert.Equal(t, context.Canceled, err)
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("the shared call must be canceled once every caller gave up")
+	}
+	assert.Equal(t, 0, g.InFlight())
+}
+
+type callerValue struct{}
+
+func (callerValue) CallerScoped() {}
+
+type valueKey string
+
+func TestGroupDropsCallerValuesFromTheSharedCall(t *testing.T) {
+	g := New()
+	var shared context.Context
+	doer := g.Wrap(heimdall.DoerFunc(func(request *http.Request) (*http.Response, error) {
+		shared = request.Context()
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+
+	ctx := heimdall.WithAttempt(context.Background(), heimdall.Attempt{Number: 1})
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{})
+	ctx = context.WithValue(ctx, valueKey("recorder"), callerValue{})
+	ctx = context.WithValue(ctx, valueKey("tenant"), "acme")
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+
+	_, err = doer.Do(request)
+	require.NoError(t, err)
+
+	_, ok := heimdall.AttemptFromContext(shared)
+	assert.False(t, ok, "the attempt belongs to the caller")
+	assert.Nil(t, httptrace.ContextClientTrace(shared), "the trace belongs to the caller")
+	assert.Nil(t, shared.Value(valueKey("recorder")))
+	assert.Equal(t, "acme", shared.Value(valueKey("tenant")))
+}
@@ -0,0 +1,122 @@
+package heimdall
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// ConnEvents holds the times of the httptrace events of a request, zero for the
+// events which did not happen, and the connection it was sent on
+type ConnEvents struct {
+	GetConn      time.Time
+	DNSStart     time.Time
+	DNSDone      time.Time
+	ConnectStart time.Time
+	ConnectDone  time.Time
+	TLSStart     time.Time
+	TLSDone      time.Time
+	FirstByte    time.Time
+	ConnInfo     httptrace.GotConnInfo
+}
+
+// DNSLookup returns the time spent resolving the host, 0 when no lookup happened
+func (e ConnEvents) DNSLookup() time.Duration {
+	return between(e.DNSStart, e.DNSDone)
+}
+
+// Connect returns the time spent dialing the remote address
+func (e ConnEvents) Connect() time.Duration {
+	return between(e.ConnectStart, e.ConnectDone)
+}
+
+// TLSHandshake returns the time spent in the TLS handshake
+func (e ConnEvents) TLSHandshake() time.Duration {
+	return between(e.TLSStart, e.TLSDone)
+}
+
+// TimeToFirstByte returns the time from start to the first response byte,
+// measured from asking for a connection when start is zero
+func (e ConnEvents) TimeToFirstByte(start time.Time) time.Duration {
+	if start.IsZero() {
+		start = e.GetConn
+	}
+	return between(start, e.FirstByte)
+}
+
+// RemoteAddr returns the address of the remote end of the connection, "" when unknown
+func (e ConnEvents) RemoteAddr() string {
+	if e.ConnInfo.Conn == nil {
+		return ""
+	}
+	addr := e.ConnInfo.Conn.RemoteAddr()
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
+
+// ConnRecorder records the httptrace events of a request. The hooks may be
+// called from the transport's goroutines, hence the mutex. Asking for a new
+// connection, as every retry does, resets the recorded events so only the last
+// attempt is kept
+type ConnRecorder struct {
+	// Clock is the clock the events are timed with, nil meaning the system
+	// clock. It must be the clock of the attempt for TimeToFirstByte to hold
+	Clock Clock
+
+	mu     sync.Mutex
+	events ConnEvents
+}
+
+// ClientTrace returns the hooks recording the events, to be attached to the
+// request context with httptrace.WithClientTrace
+func (r *ConnRecorder) ClientTrace() *httptrace.ClientTrace {
+	record := func(at *time.Time) {
+		r.mu.Lock()
+		*at = r.now()
+		r.mu.Unlock()
+	}
+
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			r.mu.Lock()
+			r.events = ConnEvents{GetConn: r.now()}
+			r.mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			r.mu.Lock()
+			r.events.ConnInfo = info
+			r.mu.Unlock()
+		},
+		DNSStart:             func(httptrace.DNSStartInfo) { record(&r.events.DNSStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { record(&r.events.DNSDone) },
+		ConnectStart:         func(string, string) { record(&r.events.ConnectStart) },
+		ConnectDone:          func(string, string, error) { record(&r.events.ConnectDone) },
+		TLSHandshakeStart:    func() { record(&r.events.TLSStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { record(&r.events.TLSDone) },
+		GotFirstResponseByte: func() { record(&r.events.FirstByte) },
+	}
+}
+
+func (r *ConnRecorder) now() time.Time {
+	if r.Clock == nil {
+		return time.Now()
+	}
+	return r.Clock.Now()
+}
+
+// Events returns a snapshot of the recorded events
+func (r *ConnRecorder) Events() ConnEvents {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.events
+}
+
+func between(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+	return end.Sub(start)
+}
@@ -0,0 +1,62 @@
+package heimdall
+
+import (
+	"net"
+	"net/http/httptrace"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type addrlessConn struct {
+	net.Conn
+}
+
+func (addrlessConn) RemoteAddr() net.Addr {
+	return nil
+}
+
+func TestConnEventsDurations(t *testing.T) {
+	start := time.Now()
+	events := ConnEvents{
+		GetConn:      start,
+		DNSStart:     start,
+		DNSDone:      start.Add(1 * time.Millisecond),
+		ConnectStart: start.Add(1 * time.Millisecond),
+		ConnectDone:  start.Add(3 * time.Millisecond),
+		FirstByte:    start.Add(10 * time.Millisecond),
+	}
+
+	assert.Equal(t, 1*time.Millisecond, events.DNSLookup())
+	assert.Equal(t, 2*time.Millisecond, events.Connect())
+	assert.Equal(t, time.Duration(0), events.TLSHandshake())
+	assert.Equal(t, 10*time.Millisecond, events.TimeToFirstByte(time.Time{}))
+	assert.Equal(t, 7*time.Millisecond, events.TimeToFirstByte(start.Add(3*time.Millisecond)))
+}
+
+func TestConnEventsRemoteAddr(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	assert.Equal(t, "", ConnEvents{}.RemoteAddr())
+	assert.Equal(t, "", ConnEvents{ConnInfo: httptrace.GotConnInfo{Conn: addrlessConn{client}}}.RemoteAddr())
+	assert.Equal(t, "pipe", ConnEvents{ConnInfo: httptrace.GotConnInfo{Conn: client}}.RemoteAddr())
+}
+
+func TestConnRecorderKeepsTheLastAttempt(t *testing.T) {
+	recorder := &ConnRecorder{}
+	trace := recorder.ClientTrace()
+
+	trace.GetConn("example.com:80")
+	trace.DNSStart(httptrace.DNSStartInfo{})
+	trace.DNSDone(httptrace.DNSDoneInfo{})
+	trace.GetConn("example.com:80")
+	trace.GotConn(httptrace.GotConnInfo{Reused: true})
+
+	events := recorder.Events()
+	assert.True(t, events.DNSStart.IsZero(), "a new connection must reset the events")
+	assert.False(t, events.GetConn.IsZero())
+	assert.True(t, events.ConnInfo.Reused)
+}
@@ -0,0 +1,123 @@
+package heimdall
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrHostNotAllowed is returned for a request to a host other than the one of
+// the base URL of the client and the hosts it explicitly allows
+var ErrHostNotAllowed = errors.New("host not allowed")
+
+const maxRedirects = 10
+
+type defaultsCtxKey struct{}
+
+// RequestDefaults completes the requests of a client with a base URL and
+// default headers. The zero value leaves requests untouched
+type RequestDefaults struct {
+	// BaseURL is the URL relative request URLs are resolved against, its path
+	// being joined with theirs
+	BaseURL string
+	// Header holds the headers sent with every request which doesn't set them
+	Header http.Header
+	// AllowedHosts are the hosts, besides the one of BaseURL, requests may be
+	// sent to. When BaseURL is set or AllowedHosts isn't empty, requests to any
+	// other host fail with ErrHostNotAllowed
+	AllowedHosts []string
+}
+
+// Apply returns a copy of request with its URL resolved and the default
+// headers added, request itself is never modified
+func (d RequestDefaults) Apply(request *http.Request) (*http.Request, error) {
+	if d.BaseURL == "" && len(d.Header) == 0 && len(d.AllowedHosts) == 0 {
+		return request, nil
+	}
+
+	u, err := d.resolve(request.URL)
+	if err != nil {
+		return nil, err
+	}
+	if !d.allowed(u.Host) {
+		return nil, errors.Wrapf(ErrHostNotAllowed, "%s", u.Host)
+	}
+
+	ctx := request.Context()
+	if d.BaseURL != "" || len(d.AllowedHosts) > 0 {
+		// for CheckRedirect to apply the same restriction to redirects
+		ctx = context.WithValue(ctx, defaultsCtxKey{}, d)
+	}
+	r := request.WithContext(ctx)
+	r.URL = u
+	r.Header = request.Header.Clone()
+	if r.Header == nil {
+		r.Header = http.Header{}
+	}
+	for key, values := range d.Header {
+		if len(r.Header.Values(key)) == 0 {
+			r.Header[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+		}
+	}
+	return r, nil
+}
+
+// resolve joins a relative u to BaseURL
+func (d RequestDefaults) resolve(u *url.URL) (*url.URL, error) {
+	if d.BaseURL == "" || u.IsAbs() || u.Host != "" {
+		return u, nil
+	}
+
+	base, err := url.Parse(d.BaseURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid base URL")
+	}
+	base.RawQuery = ""
+	base.Fragment = ""
+
+	rawURL := strings.TrimSuffix(base.String(), "/")
+	if ref := strings.TrimPrefix(u.String(), "/"); ref != "" {
+		if strings.HasPrefix(ref, "?") || strings.HasPrefix(ref, "#") {
+			rawURL += ref
+		} else {
+			rawURL += "/" + ref
+		}
+	}
+	return url.Parse(rawURL)
+}
+
+// allowed reports whether requests may be sent to host
+func (d RequestDefaults) allowed(host string) bool {
+	if d.BaseURL == "" && len(d.AllowedHosts) == 0 {
+		return true
+	}
+
+	if base, err := url.Parse(d.BaseURL); err == nil && d.BaseURL != "" && strings.EqualFold(base.Host, host) {
+		return true
+	}
+	for _, allowedHost := range d.AllowedHosts {
+		if strings.EqualFold(allowedHost, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckRedirect is an http.Client CheckRedirect policy failing redirects to
+// hosts the RequestDefaults the request was completed with don't allow with
+// ErrHostNotAllowed, so default headers never leave for another host. Like
+// the default policy, it stops after 10 redirects. Custom http.Clients given
+// to the clients should use it
+func CheckRedirect(request *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errors.Errorf("stopped after %d redirects", maxRedirects)
+	}
+
+	if d, ok := request.Context().Value(defaultsCtxKey{}).(RequestDefaults); ok && !d.allowed(request.URL.Host) {
+		return errors.Wrapf(ErrHostNotAllowed, "redirect to %s", request.URL.Host)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package heimdall
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestDefaultsResolveRelativeURLs(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		url     string
+		want    string
+	}{
+		{"https://api.example.com", "/users/1", "https://api.example.com/users/1"},
+		{"https://api.example.com/v1", "/users/1?fields=name", "https://api.example.com/v1/users/1?fields=name"},
+		{"https://api.example.com/v1/", "users/1", "https://api.example.com/v1/users/1"},
+		{"https://api.example.com/v1", "", "https://api.example.com/v1"},
+		{"https://api.example.com/v1", "?page=2", "https://api.example.com/v1?page=2"},
+		{"https://api.example.com/v1", "https://api.example.com/v2/users", "https://api.example.com/v2/users"},
+	}
+
+	for _, tt := range tests {
+		request, err := http.NewRequest(http.MethodGet, tt.url, nil)
+		require.NoError(t, err)
+
+		resolved, err := RequestDefaults{BaseURL: tt.baseURL}.Apply(request)
+		require.NoError(t, err, tt.url)
+		assert.Equal(t, tt.want, resolved.URL.String(), tt.url)
+		assert.Equal(t, tt.url, request.URL.String(), "the caller's request must not be modified")
+	}
+}
+
+func TestRequestDefaultsRefuseOtherHosts(t *testing.T) {
+	defaults := RequestDefaults{BaseURL: "https://api.example.com", AllowedHosts: []string{"cdn.example.com"}}
+
+	request, _ := http.NewRequest(http.MethodGet, "https://evil.example.org/steal", nil)
+	_, err := defaults.Apply(request)
+	assert.True(t, errors.Is(err, ErrHostNotAllowed))
+
+	request, _ = http.NewRequest(http.MethodGet, "https://CDN.example.com/logo.png", nil)
+	_, err = defaults.Apply(request)
+	assert.NoError(t, err)
+}
+
+func TestRequestDefaultsMergeHeaders(t *testing.T) {
+	defaults := RequestDefaults{Header: http.Header{
+		"User-Agent": {"heimdall"},
+		"X-Api-Key":  {"secret"},
+	}}
+
+	request, _ := http.NewRequest(http.MethodGet, "https://api.example.com", nil)
+	request.Header.Set("User-Agent", "caller")
+
+	completed, err := defaults.Apply(request)
+	require.NoError(t, err)
+
+	assert.Equal(t, "caller", completed.Header.Get("User-Agent"))
+	assert.Equal(t, "secret", completed.Header.Get("X-Api-Key"))
+	assert.Empty(t, request.Header.Get("X-Api-Key"), "the caller's headers must not be modified")
+}
+
+func TestZeroRequestDefaultsLeaveRequestUntouched(t *testing.T) {
+	request, _ := http.NewRequest(http.MethodGet, "https://api.example.com", nil)
+
+	completed, err := RequestDefaults{}.Apply(request)
+	require.NoError(t, err)
+	assert.True(t, request == completed)
+}
+
+func TestCheckRedirectRefusesOtherHosts(t *testing.T) {
+	request, _ := http.NewRequest(http.MethodGet, "/users", nil)
+	completed, err := RequestDefaults{BaseURL: "https://api.example.com"}.Apply(request)
+	require.NoError(t, err)
+
+	redirect, _ := http.NewRequestWithContext(completed.Context(), http.MethodGet, "https://evil.example.org/users", nil)
+	assert.True(t, errors.Is(CheckRedirect(redirect, []*http.Request{completed}), ErrHostNotAllowed))
+
+	redirect, _ = http.NewRequestWithContext(completed.Context(), http.MethodGet, "https://api.example.com/v2/users", nil)
+	assert.NoError(t, CheckRedirect(redirect, []*http.Request{completed}))
+
+	assert.Error(t, CheckRedirect(redirect, make([]*http.Request, 10)), "redirects must stop after 10")
+}
@@ -0,0 +1,8 @@
+package heimdall
+
+import "net/http"
+
+// FallbackFunc is called with a request which failed with err. It may return a
+// synthetic response, e.g. a cached or default one, to hand to the caller
+// instead of the error, or an error when it has none to offer
+type FallbackFunc func(request *http.Request, err error) (*http.Response, error)
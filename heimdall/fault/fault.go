@@ -0,0 +1,249 @@
+// Package fault injects faults into requests to exercise retries, circuit
+// breakers and timeouts
+package fault
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+)
+
+// ErrInjected is the error injected by Error when given none
+var ErrInjected = errors.New("fault: injected connection error")
+
+// Fault alters how a request is sent, it is a heimdall.Middleware
+type Fault = heimdall.Middleware
+
+// Rule injects a fault into a share of the requests to a host and route
+type Rule struct {
+	// Host is the host, port included if any, of the requests the rule applies to, any host if empty
+	Host string
+	// PathPrefix restricts the rule to requests whose path starts with it
+	PathPrefix string
+	// Probability is the chance, from 0 to 1, of injecting the fault into a matching request
+	Probability float64
+	// Fault is the fault injected
+	Fault Fault
+}
+
+func (r Rule) matches(request *http.Request) bool {
+	if r.Host != "" && r.Host != request.URL.Host {
+		return false
+	}
+	return strings.HasPrefix(request.URL.Path, r.PathPrefix)
+}
+
+// Injector injects the faults of its rules into requests while enabled. It
+// starts disabled. It is safe for concurrent use
+type Injector struct {
+	enabled int32
+
+	mu    sync.Mutex
+	rules []Rule
+	rand  *rand.Rand
+}
+
+// Option represents the fault injector options
+type Option func(*Injector)
+
+// WithRules sets the rules of the injector
+func WithRules(rules ...Rule) Option {
+	return func(i *Injector) {
+		i.rules = append(i.rules, rules...)
+	}
+}
+
+// WithSeed seeds the random source deciding which requests get faults, for
+// repeatable runs
+func WithSeed(seed int64) Option {
+	return func(i *Injector) {
+		i.rand = rand.New(rand.NewSource(seed))
+	}
+}
+
+// NewInjector returns a new disabled fault injector
+func NewInjector(opts ...Option) *Injector {
+	i := &Injector{
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	for _, opt := range opts {
+		opt(i)
+	}
+
+	return i
+}
+
+// Enable starts injecting faults
+func (i *Injector) Enable() {
+	atomic.StoreInt32(&i.enabled, 1)
+}
+
+// Disable stops injecting faults
+func (i *Injector) Disable() {
+	atomic.StoreInt32(&i.enabled, 0)
+}
+
+// Enabled reports whether faults are injected
+func (i *Injector) Enabled() bool {
+	return atomic.LoadInt32(&i.enabled) == 1
+}
+
+// SetRules replaces the rules of the injector
+func (i *Injector) SetRules(rules ...Rule) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.rules = append([]Rule(nil), rules...)
+}
+
+// Wrap returns a Doer injecting faults into the requests sent through next, so
+// it can be used as a heimdall.Middleware. Every matching rule is rolled for,
+// the faults drawn being applied in the order of the rules
+func (i *Injector) Wrap(next heimdall.Doer) heimdall.Doer {
+	return heimdall.DoerFunc(func(request *http.Request) (*http.Response, error) {
+		if !i.Enabled() {
+			return next.Do(request)
+		}
+		return heimdall.Chain(next, i.draw(request)...).Do(request)
+	})
+}
+
+func (i *Injector) draw(request *http.Request) []heimdall.Middleware {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var faults []heimdall.Middleware
+	for _, rule := range i.rules {
+		if rule.matches(request) && i.rand.Float64() < rule.Probability {
+			faults = append(faults, rule.Fault)
+		}
+	}
+	return faults
+}
+
+// Latency delays requests by d, or until their context is done
+func Latency(d time.Duration) Fault {
+	return func(next heimdall.Doer) heimdall.Doer {
+		return heimdall.DoerFunc(func(request *http.Request) (*http.Response, error) {
+			if err := sleep(request, d); err != nil {
+				return nil, err
+			}
+			return next.Do(request)
+		})
+	}
+}
+
+// Error fails requests with err, ErrInjected when nil, without sending them
+func Error(err error) Fault {
+	if err == nil {
+		err = ErrInjected
+	}
+	return func(heimdall.Doer) heimdall.Doer {
+		return heimdall.DoerFunc(func(*http.Request) (*http.Response, error) {
+			return nil, err
+		})
+	}
+}
+
+// Status answers requests with an empty response with the status code, without sending them
+func Status(statusCode int) Fault {
+	return func(heimdall.Doer) heimdall.Doer {
+		return heimdall.DoerFunc(func(request *http.Request) (*http.Response, error) {
+			return &http.Response{
+				Status:     fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+				StatusCode: statusCode,
+				Proto:      "HTTP/1.1",
+				ProtoMajor: 1,
+				ProtoMinor: 1,
+				Header:     http.Header{},
+				Body:       http.NoBody,
+				Request:    request,
+			}, nil
+		})
+	}
+}
+
+// TruncatedBody cuts response bodies after n bytes, reads past them failing
+// with io.ErrUnexpectedEOF
+func TruncatedBody(n int64) Fault {
+	return bodyFault(func(body io.ReadCloser, request *http.Request) io.ReadCloser {
+		return &truncatedBody{ReadCloser: body, remaining: n}
+	})
+}
+
+// SlowBody makes every read of response bodies wait for delay and return at
+// most chunkSize bytes
+func SlowBody(chunkSize int, delay time.Duration) Fault {
+	return bodyFault(func(body io.ReadCloser, request *http.Request) io.ReadCloser {
+		return &slowBody{ReadCloser: body, request: request, chunkSize: chunkSize, delay: delay}
+	})
+}
+
+func bodyFault(wrap func(io.ReadCloser, *http.Request) io.ReadCloser) Fault {
+	return func(next heimdall.Doer) heimdall.Doer {
+		return heimdall.DoerFunc(func(request *http.Request) (*http.Response, error) {
+			response, err := next.Do(request)
+			if err != nil || response == nil || response.Body == nil {
+				return response, err
+			}
+			response.Body = wrap(response.Body, request)
+			response.ContentLength = -1
+			return response, nil
+		})
+	}
+}
+
+type truncatedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+type slowBody struct {
+	io.ReadCloser
+	request   *http.Request
+	chunkSize int
+	delay     time.Duration
+}
+
+func (b *slowBody) Read(p []byte) (int, error) {
+	if err := sleep(b.request, b.delay); err != nil {
+		return 0, err
+	}
+	if b.chunkSize > 0 && len(p) > b.chunkSize {
+		p = p[:b.chunkSize]
+	}
+	return b.ReadCloser.Read(p)
+}
+
+// sleep waits for d unless the context of request is done first
+func sleep(request *http.Request, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-request.Context().Done():
+		return request.Context().Err()
+	}
+}
@@ -0,0 +1,154 @@
+package fault
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+	"github.com/go-light/httpclient/v3/heimdall/heimdalltest"
+	"github.com/go-light/httpclient/v3/heimdall/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDoer() *heimdalltest.Doer {
+	doer := heimdalltest.NewDoer()
+	doer.Expect(http.MethodGet, "/").Reply(heimdalltest.Status(http.StatusOK).Body("0123456789"))
+	return doer
+}
+
+func get(t *testing.T, doer heimdall.Doer, rawURL string) (*http.Response, error) {
+	t.Helper()
+	request, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	require.NoError(t, err)
+	return doer.Do(request)
+}
+
+func TestInjectorOnlyInjectsWhileEnabled(t *testing.T) {
+	injector := NewInjector(WithRules(Rule{Probability: 1, Fault: Status(http.StatusServiceUnavailable)}))
+	doer := injector.Wrap(newDoer())
+
+	response, err := get(t, doer, "http://example.com/")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	injector.Enable()
+	response, err = get(t, doer, "http://example.com/")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+	assert.Equal(t, "503 Service Unavailable", response.Status)
+
+	injector.Disable()
+	response, err = get(t, doer, "http://example.com/")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
+
+func TestInjectorMatchesHostAndRoute(t *testing.T) {
+	injector := NewInjector(WithRules(Rule{Host: "example.com", PathPrefix: "/users", Probability: 1, Fault: Error(nil)}))
+	injector.Enable()
+
+	doer := heimdalltest.NewDoer()
+	doer.Expect(http.MethodGet, "/orders")
+	doer.Expect(http.MethodGet, "http://other.com/users")
+	wrapped := injector.Wrap(doer)
+
+	_, err := get(t, wrapped, "http://example.com/users/1")
+	assert.Equal(t, ErrInjected, err)
+
+	_, err = get(t, wrapped, "http://example.com/orders")
+	assert.NoError(t, err)
+	_, err = get(t, wrapped, "http://other.com/users")
+	assert.NoError(t, err)
+	doer.AssertExpectations(t)
+}
+
+func TestInjectorProbabilityIsSeeded(t *testing.T) {
+	count := func() int {
+		injector := NewInjector(WithSeed(42), WithRules(Rule{Probability: 0.5, Fault: Error(nil)}))
+		injector.Enable()
+		wrapped := injector.Wrap(newDoer())
+
+		failures := 0
+		for i := 0; i < 100; i++ {
+			if _, err := get(t, wrapped, "http://example.com/"); err != nil {
+				failures++
+			}
+		}
+		return failures
+	}
+
+	failures := count()
+	assert.True(t, failures > 20 && failures < 80, "got %d failures out of 100", failures)
+	assert.Equal(t, failures, count(), "the same seed must inject the same faults")
+}
+
+func TestInjectorSetRulesAtRuntime(t *testing.T) {
+	injector := NewInjector()
+	injector.Enable()
+	wrapped := injector.Wrap(newDoer())
+
+	_, err := get(t, wrapped, "http://example.com/")
+	require.NoError(t, err)
+
+	injector.SetRules(Rule{Probability: 1, Fault: Error(errors.New("connection refused"))})
+	_, err = get(t, wrapped, "http://example.com/")
+	assert.EqualError(t, err, "connection refused")
+}
+
+func TestLatencyHonoursContext(t *testing.T) {
+	doer := Latency(time.Second)(newDoer())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com/", nil)
+	require.NoError(t, err)
+
+	_, err = doer.Do(request)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestTruncatedBody(t *testing.T) {
+	response, err := get(t, TruncatedBody(4)(newDoer()), "http://example.com/")
+	require.NoError(t, err)
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.Equal(t, "0123", string(body))
+}
+
+func TestSlowBody(t *testing.T) {
+	response, err := get(t, SlowBody(4, 5*time.Millisecond)(newDoer()), "http://example.com/")
+	require.NoError(t, err)
+	defer response.Body.Close()
+
+	start := time.Now()
+	body, err := ioutil.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(body))
+	assert.True(t, time.Since(start) >= 15*time.Millisecond, "read took only %s", time.Since(start))
+}
+
+func TestInjectorExercisesRetries(t *testing.T) {
+	injector := NewInjector(WithRules(Rule{Probability: 1, Fault: Status(http.StatusInternalServerError)}))
+	injector.Enable()
+
+	doer := newDoer()
+	client := httpclient.NewClient(
+		httpclient.WithHTTPClient(doer),
+		httpclient.WithMiddleware(injector.Wrap),
+		httpclient.WithRetryCount(2),
+		httpclient.WithRetrier(heimdall.NewRetrier(heimdall.NewConstantBackoff(time.Millisecond, time.Millisecond))),
+	)
+
+	response, err := client.Get("http://example.com/", http.Header{})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
+	assert.Equal(t, 0, doer.Calls(), "injected statuses must not reach the downstream")
+}
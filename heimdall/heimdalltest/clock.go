@@ -0,0 +1,78 @@
+package heimdalltest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+)
+
+// FakeClock is a heimdall.Clock and heimdall.Sleeper whose time only moves
+// when told to. Sleep returns at once after advancing the clock by the slept
+// duration, so code waiting on a Sleeper runs without waiting. It is safe for
+// concurrent use
+//
+//	clock := heimdalltest.NewFakeClock(time.Now())
+//	l := limiter.NewRateLimiter(10, 1, limiter.WithRateClock(clock), limiter.WithRateSleeper(clock))
+//	...
+//	assert.Equal(t, []time.Duration{100 * time.Millisecond}, clock.Sleeps())
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+var (
+	_ heimdall.Clock   = (*FakeClock)(nil)
+	_ heimdall.Sleeper = (*FakeClock)(nil)
+)
+
+// NewFakeClock returns a fake clock set to now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the time of the clock
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set moves the clock to now
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Sleep records d and advances the clock by it without waiting, unless ctx is
+// already done
+func (c *FakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sleeps = append(c.sleeps, d)
+	if d > 0 {
+		c.now = c.now.Add(d)
+	}
+	return nil
+}
+
+// Sleeps returns the durations Sleep was called with, in order
+func (c *FakeClock) Sleeps() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.sleeps...)
+}
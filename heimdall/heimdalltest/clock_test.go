@@ -0,0 +1,44 @@
+package heimdalltest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeClockMovesOnlyWhenTold(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	assert.Equal(t, start, clock.Now())
+
+	clock.Advance(time.Minute)
+	assert.Equal(t, start.Add(time.Minute), clock.Now())
+
+	clock.Set(start)
+	assert.Equal(t, start, clock.Now())
+}
+
+func TestFakeClockSleepAdvancesWithoutWaiting(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	assert.NoError(t, clock.Sleep(context.Background(), time.Hour))
+	assert.NoError(t, clock.Sleep(context.Background(), time.Minute))
+	clock.Advance(time.Second)
+
+	assert.Equal(t, start.Add(time.Hour+time.Minute+time.Second), clock.Now())
+	assert.Equal(t, []time.Duration{time.Hour, time.Minute}, clock.Sleeps())
+}
+
+func TestFakeClockSleepFailsWhenContextIsDone(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.Equal(t, context.Canceled, clock.Sleep(ctx, time.Hour))
+	assert.Equal(t, start, clock.Now())
+	assert.Empty(t, clock.Sleeps())
+}
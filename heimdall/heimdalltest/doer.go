@@ -0,0 +1,334 @@
+package heimdalltest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+)
+
+// Doer is a mock heimdall.Doer answering requests from expectations, to be
+// given to the WithHTTPClient option of the clients. It is safe for concurrent use
+//
+//	doer := heimdalltest.NewDoer()
+//	doer.Expect(http.MethodGet, "/users/1").
+//		Reply(heimdalltest.Status(http.StatusServiceUnavailable)).
+//		Reply(heimdalltest.Status(http.StatusOK).Body(`{"id":1}`))
+//	client := httpclient.NewClient(httpclient.WithHTTPClient(doer), httpclient.WithRetryCount(1))
+//	...
+//	doer.AssertExpectations(t)
+type Doer struct {
+	mu           sync.Mutex
+	expectations []*Expectation
+	unexpected   []string
+	calls        int
+}
+
+var _ heimdall.Doer = (*Doer)(nil)
+
+// NewDoer returns a new mock Doer without expectations
+func NewDoer() *Doer {
+	return &Doer{}
+}
+
+// Expect adds an expectation for requests with method and rawURL. rawURL may
+// be a full URL or only a path, with or without query
+func (d *Doer) Expect(method, rawURL string) *Expectation {
+	expected, err := url.Parse(rawURL)
+	if err != nil {
+		panic(fmt.Sprintf("heimdalltest: invalid URL %q: %v", rawURL, err))
+	}
+
+	e := &Expectation{doer: d, method: method, url: expected, header: http.Header{}}
+	d.mu.Lock()
+	d.expectations = append(d.expectations, e)
+	d.mu.Unlock()
+	return e
+}
+
+// Do answers request with the next reply of the first expectation it
+// matches, requests matching none failing with an error
+func (d *Doer) Do(request *http.Request) (*http.Response, error) {
+	body, err := readBody(request)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	d.calls++
+	var matched *Expectation
+	for _, e := range d.expectations {
+		if e.exhausted() || !e.matches(request, body) {
+			continue
+		}
+		matched = e
+		break
+	}
+	if matched == nil {
+		description := request.Method + " " + request.URL.String()
+		d.unexpected = append(d.unexpected, description)
+		d.mu.Unlock()
+		return nil, fmt.Errorf("heimdalltest: unexpected request %s", description)
+	}
+	reply := matched.next()
+	d.mu.Unlock()
+
+	return reply.respond(request)
+}
+
+// Calls returns the number of requests received
+func (d *Doer) Calls() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.calls
+}
+
+// AssertExpectations reports every expectation which didn't get as many calls
+// as expected and every request which matched no expectation. It returns
+// whether all went as expected
+func (d *Doer) AssertExpectations(t TestingT) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ok := true
+	for _, e := range d.expectations {
+		if e.unmet() {
+			t.Errorf("heimdalltest: expected %s, got %d calls", e, e.calls)
+			ok = false
+		}
+	}
+	for _, request := range d.unexpected {
+		t.Errorf("heimdalltest: unexpected request %s", request)
+		ok = false
+	}
+	return ok
+}
+
+// Expectation describes the requests a Doer expects and how it replies to them
+type Expectation struct {
+	doer   *Doer
+	method string
+	url    *url.URL
+	header http.Header
+	body   interface{} // decoded JSON, nil when the body isn't matched
+	times  int
+
+	replies []*Reply
+	calls   int
+}
+
+// WithHeader makes the expectation match only requests with the header value
+func (e *Expectation) WithHeader(name, value string) *Expectation {
+	e.header.Add(name, value)
+	return e
+}
+
+// WithJSONBody makes the expectation match only requests whose body is
+// JSON equal to body, regardless of formatting and key order
+func (e *Expectation) WithJSONBody(body string) *Expectation {
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(body), &decoded); err != nil {
+		panic(fmt.Sprintf("heimdalltest: invalid JSON body %q: %v", body, err))
+	}
+	e.body = decoded
+	return e
+}
+
+// Times sets how many calls the expectation gets, later requests falling
+// through to the next expectations. By default it matches any number of
+// calls and must get at least one
+func (e *Expectation) Times(times int) *Expectation {
+	e.times = times
+	return e
+}
+
+// Reply adds a reply to the sequence of the expectation, the nth call getting
+// the nth reply and calls past the end of the sequence the last one. Without
+// replies calls get an empty 200 response
+func (e *Expectation) Reply(reply *Reply) *Expectation {
+	e.replies = append(e.replies, reply)
+	return e
+}
+
+// Calls returns the number of requests the expectation matched
+func (e *Expectation) Calls() int {
+	e.doer.mu.Lock()
+	defer e.doer.mu.Unlock()
+	return e.calls
+}
+
+func (e *Expectation) String() string {
+	description := e.method + " " + e.url.String()
+	if e.times > 0 {
+		description += fmt.Sprintf(" %d times", e.times)
+	}
+	return description
+}
+
+func (e *Expectation) exhausted() bool {
+	return e.times > 0 && e.calls >= e.times
+}
+
+func (e *Expectation) unmet() bool {
+	if e.times > 0 {
+		return e.calls < e.times
+	}
+	return e.calls == 0
+}
+
+func (e *Expectation) next() *Reply {
+	e.calls++
+	if len(e.replies) == 0 {
+		return Status(http.StatusOK)
+	}
+	if e.calls > len(e.replies) {
+		return e.replies[len(e.replies)-1]
+	}
+	return e.replies[e.calls-1]
+}
+
+func (e *Expectation) matches(request *http.Request, body []byte) bool {
+	if e.method != request.Method || !matchesURL(e.url, request.URL) {
+		return false
+	}
+
+	for name, values := range e.header {
+		for _, value := range values {
+			if !contains(request.Header.Values(name), value) {
+				return false
+			}
+		}
+	}
+
+	if e.body != nil {
+		var decoded interface{}
+		if err := json.Unmarshal(body, &decoded); err != nil || !reflect.DeepEqual(e.body, decoded) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesURL compares the parts given in expected: scheme and host when
+// set, path, and query parameters when set
+func matchesURL(expected, actual *url.URL) bool {
+	if expected.Host != "" && (expected.Scheme != actual.Scheme || expected.Host != actual.Host) {
+		return false
+	}
+	if strings.TrimSuffix(expected.Path, "/") != strings.TrimSuffix(actual.Path, "/") {
+		return false
+	}
+	if expected.RawQuery != "" && !reflect.DeepEqual(expected.Query(), actual.Query()) {
+		return false
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// readBody reads the body of request and puts it back
+func readBody(request *http.Request) ([]byte, error) {
+	if request.Body == nil || request.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := ioutil.ReadAll(request.Body)
+	request.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// Reply describes how a Doer answers a request
+type Reply struct {
+	status int
+	header http.Header
+	body   []byte
+	delay  time.Duration
+	err    error
+}
+
+// Status returns a reply with the status code and an empty body
+func Status(status int) *Reply {
+	return &Reply{status: status, header: http.Header{}}
+}
+
+// Error returns a reply failing the request with err
+func Error(err error) *Reply {
+	return &Reply{err: err, header: http.Header{}}
+}
+
+// Header adds a header value to the response
+func (r *Reply) Header(name, value string) *Reply {
+	r.header.Add(name, value)
+	return r
+}
+
+// Body sets the body of the response
+func (r *Reply) Body(body string) *Reply {
+	r.body = []byte(body)
+	return r
+}
+
+// JSON sets the body of the response to v encoded as JSON, with a JSON content type
+func (r *Reply) JSON(v interface{}) *Reply {
+	body, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("heimdalltest: can't encode JSON body: %v", err))
+	}
+	r.body = body
+	r.header.Set("Content-Type", "application/json")
+	return r
+}
+
+// Delay delays the reply, or until the request context is done
+func (r *Reply) Delay(delay time.Duration) *Reply {
+	r.delay = delay
+	return r
+}
+
+func (r *Reply) respond(request *http.Request) (*http.Response, error) {
+	if r.delay > 0 {
+		timer := time.NewTimer(r.delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-request.Context().Done():
+			return nil, request.Context().Err()
+		}
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.status, http.StatusText(r.status)),
+		StatusCode:    r.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        r.header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(r.body)),
+		ContentLength: int64(len(r.body)),
+		Request:       request,
+	}, nil
+}
@@ -0,0 +1,135 @@
+package heimdalltest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+	"github.com/go-light/httpclient/v3/heimdall/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingT struct {
+	errors []string
+}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestDoerRepliesInSequence(t *testing.T) {
+	doer := NewDoer()
+	users := doer.Expect(http.MethodGet, "http://example.com/users/1").
+		Reply(Status(http.StatusServiceUnavailable)).
+		Reply(Status(http.StatusOK).Header("X-Id", "1").JSON(map[string]int{"id": 1}))
+
+	client := httpclient.NewClient(
+		httpclient.WithHTTPClient(doer),
+		httpclient.WithRetryCount(1),
+		httpclient.WithRetrier(heimdall.NewRetrier(heimdall.NewConstantBackoff(time.Millisecond, time.Millisecond))),
+	)
+
+	response, err := client.Get("http://example.com/users/1", http.Header{})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "1", response.Header.Get("X-Id"))
+	assert.JSONEq(t, `{"id":1}`, ResponseBody(t, response))
+
+	assert.Equal(t, 2, users.Calls())
+	assert.Equal(t, 2, doer.Calls())
+	assert.True(t, doer.AssertExpectations(t))
+}
+
+func TestDoerMatchesHeadersAndJSONBody(t *testing.T) {
+	doer := NewDoer()
+	doer.Expect(http.MethodPost, "/users").
+		WithHeader("Authorization", "Bearer token").
+		WithJSONBody(`{"name": "jane", "age": 30}`).
+		Reply(Status(http.StatusCreated).Body("created"))
+
+	client := httpclient.NewClient(httpclient.WithHTTPClient(doer))
+
+	response, err := client.Post("http://example.com/users", strings.NewReader(`{"age":30,"name":"jane"}`),
+		http.Header{"Authorization": {"Bearer token"}})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, response.StatusCode)
+	assert.Equal(t, "created", ResponseBody(t, response))
+
+	_, err = client.Post("http://example.com/users", strings.NewReader(`{"name":"john"}`),
+		http.Header{"Authorization": {"Bearer token"}})
+	assert.Error(t, err)
+
+	recorder := &recordingT{}
+	assert.False(t, doer.AssertExpectations(recorder))
+	assert.Equal(t, []string{"heimdalltest: unexpected request POST http://example.com/users"}, recorder.errors)
+}
+
+func TestDoerMatchesQuery(t *testing.T) {
+	doer := NewDoer()
+	doer.Expect(http.MethodGet, "/search?q=go&page=2").Reply(Status(http.StatusOK).Body("page 2"))
+
+	request, err := http.NewRequest(http.MethodGet, "http://example.com/search?page=2&q=go", nil)
+	require.NoError(t, err)
+	response, err := doer.Do(request)
+	require.NoError(t, err)
+	assert.Equal(t, "page 2", ResponseBody(t, response))
+
+	request, err = http.NewRequest(http.MethodGet, "http://example.com/search?page=3&q=go", nil)
+	require.NoError(t, err)
+	_, err = doer.Do(request)
+	assert.Error(t, err)
+}
+
+func TestDoerReportsUnmetExpectations(t *testing.T) {
+	doer := NewDoer()
+	doer.Expect(http.MethodGet, "/called").Times(2)
+	doer.Expect(http.MethodDelete, "/never")
+
+	request, err := http.NewRequest(http.MethodGet, "http://example.com/called", nil)
+	require.NoError(t, err)
+	_, err = doer.Do(request)
+	require.NoError(t, err)
+
+	recorder := &recordingT{}
+	assert.False(t, doer.AssertExpectations(recorder))
+	assert.Equal(t, []string{
+		"heimdalltest: expected GET /called 2 times, got 1 calls",
+		"heimdalltest: expected DELETE /never, got 0 calls",
+	}, recorder.errors)
+}
+
+func TestDoerTimesFallsThroughToNextExpectation(t *testing.T) {
+	doer := NewDoer()
+	doer.Expect(http.MethodGet, "/flaky").Times(1).Reply(Error(errors.New("connection reset")))
+	doer.Expect(http.MethodGet, "/flaky").Reply(Status(http.StatusOK))
+
+	request, err := http.NewRequest(http.MethodGet, "http://example.com/flaky", nil)
+	require.NoError(t, err)
+
+	_, err = doer.Do(request)
+	assert.EqualError(t, err, "connection reset")
+
+	response, err := doer.Do(request)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.True(t, doer.AssertExpectations(t))
+}
+
+func TestDoerDelayHonoursContext(t *testing.T) {
+	doer := NewDoer()
+	doer.Expect(http.MethodGet, "/slow").Reply(Status(http.StatusOK).Delay(time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com/slow", nil)
+	require.NoError(t, err)
+
+	_, err = doer.Do(request)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
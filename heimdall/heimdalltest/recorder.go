@@ -0,0 +1,320 @@
+package heimdalltest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+)
+
+// Mode tells whether a Recorder records real interactions or replays them
+type Mode int
+
+const (
+	// ModeReplay serves the interactions of the golden file, without network
+	ModeReplay Mode = iota
+	// ModeRecord sends requests through the real Doer and records them
+	ModeRecord
+)
+
+// Fixture is the content of a golden file
+type Fixture struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request and its response
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a request kept in a golden file
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// RecordedResponse is a response kept in a golden file
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       Body        `json:"body,omitempty"`
+}
+
+// Body is a body kept in a golden file, as text when it is valid UTF-8 and
+// base64 encoded otherwise, so golden files stay readable and diffable
+type Body []byte
+
+// MarshalJSON encodes the body as a string, or as {"base64": "..."} for binary bodies
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+// UnmarshalJSON decodes a body encoded by MarshalJSON
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*b = Body(text)
+		return nil
+	}
+
+	var encoded map[string]string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded["base64"])
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// Matcher tells whether a request matches a recorded one
+type Matcher func(request *http.Request, body []byte, recorded RecordedRequest) bool
+
+// MatchMethodURL matches requests with the same method and URL, the default
+func MatchMethodURL(request *http.Request, body []byte, recorded RecordedRequest) bool {
+	return request.Method == recorded.Method && request.URL.String() == recorded.URL
+}
+
+// MatchBody matches requests with the same body
+func MatchBody(request *http.Request, body []byte, recorded RecordedRequest) bool {
+	return bytes.Equal(body, recorded.Body)
+}
+
+// MatchHeaders matches requests with the same values for the given headers.
+// A header recorded as redacted matches any value, as long as the request has one
+func MatchHeaders(names ...string) Matcher {
+	return func(request *http.Request, body []byte, recorded RecordedRequest) bool {
+		for _, name := range names {
+			values, recordedValues := request.Header.Values(name), recorded.Header.Values(name)
+			if len(recordedValues) == 1 && recordedValues[0] == heimdall.Redacted && len(values) > 0 {
+				continue
+			}
+			if fmt.Sprint(values) != fmt.Sprint(recordedValues) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchAll matches requests matched by every matcher
+func MatchAll(matchers ...Matcher) Matcher {
+	return func(request *http.Request, body []byte, recorded RecordedRequest) bool {
+		for _, match := range matchers {
+			if !match(request, body, recorded) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Recorder is a heimdall.Doer recording real interactions to a golden file,
+// or replaying them, so tests against downstream services can run offline.
+// It is safe for concurrent use
+//
+//	mode := heimdalltest.ModeReplay
+//	if os.Getenv("RECORD") != "" {
+//		mode = heimdalltest.ModeRecord
+//	}
+//	recorder, err := heimdalltest.NewRecorder("testdata/users.json", mode)
+//	...
+//	defer recorder.Save()
+type Recorder struct {
+	path            string
+	mode            Mode
+	doer            heimdall.Doer
+	match           Matcher
+	redactedHeaders []string
+
+	mu           sync.Mutex
+	interactions []Interaction
+	replayed     []bool
+}
+
+var _ heimdall.Doer = (*Recorder)(nil)
+
+// RecorderOption represents the recorder options
+type RecorderOption func(*Recorder)
+
+// WithRealDoer sets the Doer sending requests in record mode, http.DefaultClient by default
+func WithRealDoer(doer heimdall.Doer) RecorderOption {
+	return func(r *Recorder) {
+		r.doer = doer
+	}
+}
+
+// WithMatcher sets how requests are matched to recorded ones in replay mode,
+// MatchMethodURL by default
+func WithMatcher(match Matcher) RecorderOption {
+	return func(r *Recorder) {
+		r.match = match
+	}
+}
+
+// WithRedactedHeaders adds headers whose values are replaced by [REDACTED]
+// before being recorded, on top of heimdall.RedactedHeaders
+func WithRedactedHeaders(names ...string) RecorderOption {
+	return func(r *Recorder) {
+		r.redactedHeaders = append(r.redactedHeaders, names...)
+	}
+}
+
+// NewRecorder returns a recorder for the golden file at path, which is read
+// in replay mode
+func NewRecorder(path string, mode Mode, opts ...RecorderOption) (*Recorder, error) {
+	r := &Recorder{
+		path:            path,
+		mode:            mode,
+		doer:            http.DefaultClient,
+		match:           MatchMethodURL,
+		redactedHeaders: heimdall.RedactedHeaders(),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if mode == ModeReplay {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var fixture Fixture
+		if err := json.Unmarshal(data, &fixture); err != nil {
+			return nil, fmt.Errorf("heimdalltest: invalid golden file %s: %v", path, err)
+		}
+		r.interactions = fixture.Interactions
+		r.replayed = make([]bool, len(r.interactions))
+	}
+
+	return r, nil
+}
+
+// Do records or replays request depending on the mode
+func (r *Recorder) Do(request *http.Request) (*http.Response, error) {
+	body, err := readBody(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.mode == ModeReplay {
+		return r.replay(request, body)
+	}
+	return r.record(request, body)
+}
+
+// Save writes the recorded interactions to the golden file, creating its
+// directory if needed. It does nothing in replay mode
+func (r *Recorder) Save() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+
+	r.mu.Lock()
+	data, err := json.MarshalIndent(Fixture{Interactions: r.interactions}, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.path, append(data, '\n'), 0644)
+}
+
+// replay serves the first recorded interaction matching request not replayed
+// yet, or the last matching one when all were
+func (r *Recorder) replay(request *http.Request, body []byte) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	last := -1
+	for i, interaction := range r.interactions {
+		if !r.match(request, body, interaction.Request) {
+			continue
+		}
+		last = i
+		if !r.replayed[i] {
+			break
+		}
+	}
+	if last < 0 {
+		return nil, fmt.Errorf("heimdalltest: no recorded interaction for %s %s", request.Method, request.URL)
+	}
+
+	r.replayed[last] = true
+	recorded := r.interactions[last].Response
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recorded.Header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       request,
+	}, nil
+}
+
+func (r *Recorder) record(request *http.Request, body []byte) (*http.Response, error) {
+	response, err := r.doer.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(responseBody))
+
+	interaction := Interaction{
+		Request: RecordedRequest{
+			Method: request.Method,
+			URL:    request.URL.String(),
+			Header: r.redact(request.Header),
+			Body:   body,
+		},
+		Response: RecordedResponse{
+			StatusCode: response.StatusCode,
+			Header:     r.redact(response.Header),
+			Body:       responseBody,
+		},
+	}
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, interaction)
+	r.mu.Unlock()
+
+	return response, nil
+}
+
+func (r *Recorder) redact(header http.Header) http.Header {
+	redactedHeader := header.Clone()
+	for _, name := range r.redactedHeaders {
+		if values := redactedHeader.Values(name); len(values) > 0 {
+			redactedHeader.Set(name, heimdall.Redacted)
+		}
+	}
+	return redactedHeader
+}
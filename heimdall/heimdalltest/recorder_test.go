@@ -0,0 +1,132 @@
+package heimdalltest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-light/httpclient/v3/heimdall/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorderRecordsAndReplays(t *testing.T) {
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("X-Count", string(rune('0'+count)))
+		_, _ = w.Write([]byte("hello " + r.URL.Query().Get("name")))
+	}))
+	path := filepath.Join(t.TempDir(), "fixtures", "hello.json")
+
+	recorder, err := NewRecorder(path, ModeRecord)
+	require.NoError(t, err)
+	client := httpclient.NewClient(httpclient.WithHTTPClient(recorder))
+
+	for _, name := range []string{"jane", "john"} {
+		response, err := client.Get(server.URL+"?name="+name, http.Header{"Authorization": {"Bearer secret"}})
+		require.NoError(t, err)
+		assert.Equal(t, "hello "+name, ResponseBody(t, response), "recording must hand the real response over")
+	}
+	require.NoError(t, recorder.Save())
+	server.Close()
+
+	golden, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(golden), "secret")
+	assert.Contains(t, string(golden), `"[REDACTED]"`)
+
+	replayer, err := NewRecorder(path, ModeReplay)
+	require.NoError(t, err)
+	client = httpclient.NewClient(httpclient.WithHTTPClient(replayer))
+
+	response, err := client.Get(server.URL+"?name=john", http.Header{})
+	require.NoError(t, err)
+	assert.Equal(t, "2", response.Header.Get("X-Count"))
+	assert.Equal(t, "hello john", ResponseBody(t, response))
+
+	_, err = client.Get(server.URL+"?name=jack", http.Header{})
+	assert.Error(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestRecorderReplaysInOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retry.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"interactions": [
+		{"request": {"method": "GET", "url": "http://example.com/a"}, "response": {"status_code": 503}},
+		{"request": {"method": "GET", "url": "http://example.com/a"}, "response": {"status_code": 200, "body": "ok"}}
+	]}`), 0644))
+
+	replayer, err := NewRecorder(path, ModeReplay)
+	require.NoError(t, err)
+
+	var statuses []int
+	for i := 0; i < 3; i++ {
+		request, err := http.NewRequest(http.MethodGet, "http://example.com/a", nil)
+		require.NoError(t, err)
+		response, err := replayer.Do(request)
+		require.NoError(t, err)
+		response.Body.Close()
+		statuses = append(statuses, response.StatusCode)
+	}
+	assert.Equal(t, []int{503, 200, 200}, statuses)
+}
+
+func TestRecorderMatchesWithCustomRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bodies.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"interactions": [
+		{"request": {"method": "POST", "url": "http://example.com/a", "header": {"X-Tenant": ["1"]}, "body": "{\"id\":1}"}, "response": {"status_code": 200, "body": "one"}},
+		{"request": {"method": "POST", "url": "http://example.com/a", "header": {"X-Tenant": ["1"]}, "body": "{\"id\":2}"}, "response": {"status_code": 200, "body": "two"}}
+	]}`), 0644))
+
+	replayer, err := NewRecorder(path, ModeReplay, WithMatcher(MatchAll(MatchMethodURL, MatchHeaders("X-Tenant"), MatchBody)))
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPost, "http://example.com/a", strings.NewReader(`{"id":2}`))
+	require.NoError(t, err)
+	request.Header.Set("X-Tenant", "1")
+	response, err := replayer.Do(request)
+	require.NoError(t, err)
+	assert.Equal(t, "two", ResponseBody(t, response))
+
+	request, err = http.NewRequest(http.MethodPost, "http://example.com/a", strings.NewReader(`{"id":2}`))
+	require.NoError(t, err)
+	request.Header.Set("X-Tenant", "2")
+	_, err = replayer.Do(request)
+	assert.Error(t, err)
+}
+
+func TestRecorderMatchesRedactedHeaders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"interactions": [
+		{"request": {"method": "GET", "url": "http://example.com/a", "header": {"Authorization": ["[REDACTED]"]}}, "response": {"status_code": 200, "body": "ok"}}
+	]}`), 0644))
+
+	replayer, err := NewRecorder(path, ModeReplay, WithMatcher(MatchAll(MatchMethodURL, MatchHeaders("Authorization"))))
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodGet, "http://example.com/a", nil)
+	require.NoError(t, err)
+	_, err = replayer.Do(request)
+	assert.Error(t, err, "a redacted header must still be sent")
+
+	request.Header.Set("Authorization", "Bearer secret")
+	response, err := replayer.Do(request)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", ResponseBody(t, response))
+}
+
+func TestBodyKeepsBinaryContent(t *testing.T) {
+	binary := Body{0xff, 0xfe, 0x00}
+	data, err := binary.MarshalJSON()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"base64": "//4A"}`, string(data))
+
+	var decoded Body
+	require.NoError(t, decoded.UnmarshalJSON(data))
+	assert.Equal(t, binary, decoded)
+}
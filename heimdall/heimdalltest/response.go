@@ -0,0 +1,26 @@
+// Package heimdalltest provides test helpers and doubles for heimdall clients
+package heimdalltest
+
+import (
+	"io/ioutil"
+	"net/http"
+)
+
+// TestingT is the subset of *testing.T used to report failures
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+// ResponseBody reads and closes the body of response, reporting a read failure to t
+func ResponseBody(t TestingT, response *http.Response) string {
+	if helper, ok := t.(interface{ Helper() }); ok {
+		helper.Helper()
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Errorf("heimdalltest: reading the response body: %v", err)
+	}
+	return string(body)
+}
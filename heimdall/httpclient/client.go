@@ -20,6 +20,12 @@ type Client struct {
 	retryCount int
 	retrier    heimdall.Retriable
 	plugins    []heimdall.Plugin
+	keepAlive  bool
+	clock      heimdall.Clock
+	sleeper    heimdall.Sleeper
+	defaults   heimdall.RequestDefaults
+
+	middlewares []heimdall.Middleware
 }
 
 const (
@@ -35,6 +41,8 @@ func NewClient(opts ...Option) *Client {
 		timeout:    defaultHTTPTimeout,
 		retryCount: defaultRetryCount,
 		retrier:    heimdall.NewNoRetrier(),
+		clock:      heimdall.NewSystemClock(),
+		sleeper:    heimdall.NewSystemSleeper(),
 	}
 
 	for _, opt := range opts {
@@ -43,10 +51,13 @@ func NewClient(opts ...Option) *Client {
 
 	if client.client == nil {
 		client.client = &http.Client{
-			Timeout: client.timeout,
+			Timeout:       client.timeout,
+			CheckRedirect: heimdall.CheckRedirect,
 		}
 	}
 
+	client.client = heimdall.Chain(client.client, client.middlewares...)
+
 	return &client
 }
 
@@ -122,7 +133,15 @@ func (c *Client) Delete(url string, headers http.Header) (*http.Response, error)
 
 // Do makes an HTTP request with the native `http.Do` interface
 func (c *Client) Do(request *http.Request) (*http.Response, error) {
-	request.Close = true
+	// Work on a copy so the caller's request and headers, which may be shared
+	// across goroutines, are never modified
+	request, err := c.defaults.Apply(request.Clone(request.Context()))
+	if err != nil {
+		return nil, err
+	}
+	if !c.keepAlive {
+		request.Close = true
+	}
 
 	var bodyReader *bytes.Reader
 
@@ -133,19 +152,39 @@ func (c *Client) Do(request *http.Request) (*http.Response, error) {
 		}
 		bodyReader = bytes.NewReader(reqData)
 		request.Body = ioutil.NopCloser(bodyReader) // prevents closing the body between retries
+		// GetBody lets plugins read the body without consuming the one being sent
+		request.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(reqData)), nil
+		}
 	}
 
 	multiErr := &valkyrie.MultiError{}
 	var response *http.Response
+	requestStart := c.clock.Now()
+	var backoffTime time.Duration
+	retryCount := c.retryCount
+	if n, ok := heimdall.RetryCountFromContext(request.Context()); ok {
+		retryCount = n
+	}
 
-	for i := 0; i <= c.retryCount; i++ {
+	for i := 0; i <= retryCount; i++ {
 		if response != nil {
 			response.Body.Close()
 		}
 
-		c.reportRequestStart(request)
+		// Every attempt is sent as a copy of the request carrying its own context,
+		// so plugins never have to modify the caller's request
+		attemptRequest := heimdall.AttemptRequest(request, heimdall.Attempt{
+			Number:       i,
+			RequestStart: requestStart,
+			Start:        c.clock.Now(),
+			Clock:        c.clock,
+			Backoff:      backoffTime,
+		}, c.plugins)
+
+		c.reportRequestStart(attemptRequest)
 		var err error
-		response, err = c.client.Do(request)
+		response, err = c.client.Do(attemptRequest)
 		if bodyReader != nil {
 			// Reset the body reader after the request since at this point it's already read
 			// Note that it's safe to ignore the error here since the 0,0 position is always valid
@@ -154,16 +193,19 @@ func (c *Client) Do(request *http.Request) (*http.Response, error) {
 
 		if err != nil {
 			multiErr.Push(err.Error())
-			c.reportError(request, err)
-			backoffTime := c.retrier.NextInterval(i)
-			time.Sleep(backoffTime)
+			c.reportError(attemptRequest, err)
+			if backoffTime, err = c.backoff(request, i, retryCount); err != nil {
+				multiErr.Push(err.Error())
+				break
+			}
 			continue
 		}
-		c.reportRequestEnd(request, response)
+		c.reportRequestEnd(attemptRequest, response)
 
 		if response.StatusCode >= http.StatusInternalServerError {
-			backoffTime := c.retrier.NextInterval(i)
-			time.Sleep(backoffTime)
+			if backoffTime, err = c.backoff(request, i, retryCount); err != nil {
+				break
+			}
 			continue
 		}
 
@@ -174,6 +216,17 @@ func (c *Client) Do(request *http.Request) (*http.Response, error) {
 	return response, multiErr.HasError()
 }
 
+// backoff waits before the retry following attempt i, if any. It fails when
+// the request context is done before the wait is over
+func (c *Client) backoff(request *http.Request, i, retryCount int) (time.Duration, error) {
+	if i == retryCount {
+		return 0, nil
+	}
+
+	backoffTime := c.retrier.NextInterval(i)
+	return backoffTime, c.sleeper.Sleep(request.Context(), backoffTime)
+}
+
 func (c *Client) reportRequestStart(request *http.Request) {
 	for _, plugin := range c.plugins {
 		plugin.OnRequestStart(request)
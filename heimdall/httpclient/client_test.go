@@ -2,12 +2,16 @@ package httpclient
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/go-light/httpclient/v3/heimdall"
+	"github.com/go-light/httpclient/v3/heimdall/heimdalltest"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -68,7 +72,7 @@ func TestHTTPClientGetSuccess(t *testing.T) {
 	require.NoError(t, err, "should not have failed to make a GET request")
 
 	assert.Equal(t, http.StatusOK, response.StatusCode)
-	assert.Equal(t, "{ \"response\": \"ok\" }", respBody(t, response))
+	assert.Equal(t, "{ \"response\": \"ok\" }", heimdalltest.ResponseBody(t, response))
 }
 
 func TestHTTPClientPostSuccess(t *testing.T) {
@@ -103,7 +107,7 @@ func TestHTTPClientPostSuccess(t *testing.T) {
 	require.NoError(t, err, "should not have failed to make a POST request")
 
 	assert.Equal(t, http.StatusOK, response.StatusCode)
-	assert.Equal(t, "{ \"response\": \"ok\" }", respBody(t, response))
+	assert.Equal(t, "{ \"response\": \"ok\" }", heimdalltest.ResponseBody(t, response))
 }
 
 func TestHTTPClientDeleteSuccess(t *testing.T) {
@@ -129,7 +133,7 @@ func TestHTTPClientDeleteSuccess(t *testing.T) {
 	require.NoError(t, err, "should not have failed to make a DELETE request")
 
 	assert.Equal(t, http.StatusOK, response.StatusCode)
-	assert.Equal(t, "{ \"response\": \"ok\" }", respBody(t, response))
+	assert.Equal(t, "{ \"response\": \"ok\" }", heimdalltest.ResponseBody(t, response))
 }
 
 func TestHTTPClientPutSuccess(t *testing.T) {
@@ -164,7 +168,7 @@ func TestHTTPClientPutSuccess(t *testing.T) {
 	require.NoError(t, err, "should not have failed to make a PUT request")
 
 	assert.Equal(t, http.StatusOK, response.StatusCode)
-	assert.Equal(t, "{ \"response\": \"ok\" }", respBody(t, response))
+	assert.Equal(t, "{ \"response\": \"ok\" }", heimdalltest.ResponseBody(t, response))
 }
 
 func TestHTTPClientPatchSuccess(t *testing.T) {
@@ -199,7 +203,7 @@ func TestHTTPClientPatchSuccess(t *testing.T) {
 	require.NoError(t, err, "should not have failed to make a PATCH request")
 
 	assert.Equal(t, http.StatusOK, response.StatusCode)
-	assert.Equal(t, "{ \"response\": \"ok\" }", respBody(t, response))
+	assert.Equal(t, "{ \"response\": \"ok\" }", heimdalltest.ResponseBody(t, response))
 }
 
 func TestHTTPClientGetRetriesOnFailure(t *testing.T) {
@@ -208,11 +212,13 @@ func TestHTTPClientGetRetriesOnFailure(t *testing.T) {
 	noOfCalls := noOfRetries + 1
 	backoffInterval := 1 * time.Millisecond
 	maximumJitterInterval := 1 * time.Millisecond
+	clock := heimdalltest.NewFakeClock(time.Now())
 
 	client := NewClient(
 		WithHTTPTimeout(10*time.Millisecond),
 		WithRetryCount(noOfRetries),
 		WithRetrier(heimdall.NewRetrier(heimdall.NewConstantBackoff(backoffInterval, maximumJitterInterval))),
+		WithSleeper(clock),
 	)
 
 	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
@@ -228,9 +234,10 @@ func TestHTTPClientGetRetriesOnFailure(t *testing.T) {
 	require.NoError(t, err, "should have failed to make GET request")
 
 	require.Equal(t, http.StatusInternalServerError, response.StatusCode)
-	require.Equal(t, "{ \"response\": \"something went wrong\" }", respBody(t, response))
+	require.Equal(t, "{ \"response\": \"something went wrong\" }", heimdalltest.ResponseBody(t, response))
 
 	assert.Equal(t, noOfCalls, count)
+	assert.Len(t, clock.Sleeps(), noOfRetries)
 }
 
 func BenchmarkHTTPClientGetRetriesOnFailure(b *testing.B) {
@@ -263,11 +270,13 @@ func TestHTTPClientPostRetriesOnFailure(t *testing.T) {
 	noOfCalls := noOfRetries + 1
 	backoffInterval := 1 * time.Millisecond
 	maximumJitterInterval := 1 * time.Millisecond
+	clock := heimdalltest.NewFakeClock(time.Now())
 
 	client := NewClient(
 		WithHTTPTimeout(10*time.Millisecond),
 		WithRetryCount(noOfRetries),
 		WithRetrier(heimdall.NewRetrier(heimdall.NewConstantBackoff(backoffInterval, maximumJitterInterval))),
+		WithSleeper(clock),
 	)
 
 	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
@@ -283,9 +292,10 @@ func TestHTTPClientPostRetriesOnFailure(t *testing.T) {
 	require.NoError(t, err, "should have failed to make GET request")
 
 	require.Equal(t, http.StatusInternalServerError, response.StatusCode)
-	require.Equal(t, "{ \"response\": \"something went wrong\" }", respBody(t, response))
+	require.Equal(t, "{ \"response\": \"something went wrong\" }", heimdalltest.ResponseBody(t, response))
 
 	assert.Equal(t, noOfCalls, count)
+	assert.Len(t, clock.Sleeps(), noOfRetries)
 }
 
 func BenchmarkHTTPClientPostRetriesOnFailure(b *testing.B) {
@@ -317,11 +327,13 @@ func TestHTTPClientGetReturnsNoErrorsIfRetriesFailWith5xx(t *testing.T) {
 	noOfRetries := 2
 	backoffInterval := 1 * time.Millisecond
 	maximumJitterInterval := 1 * time.Millisecond
+	clock := heimdalltest.NewFakeClock(time.Now())
 
 	client := NewClient(
 		WithHTTPTimeout(10*time.Millisecond),
 		WithRetryCount(noOfRetries),
 		WithRetrier(heimdall.NewRetrier(heimdall.NewConstantBackoff(backoffInterval, maximumJitterInterval))),
+		WithSleeper(clock),
 	)
 
 	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
@@ -338,7 +350,8 @@ func TestHTTPClientGetReturnsNoErrorsIfRetriesFailWith5xx(t *testing.T) {
 
 	require.Equal(t, noOfRetries+1, count)
 	require.Equal(t, http.StatusInternalServerError, response.StatusCode)
-	require.Equal(t, "{ \"response\": \"something went wrong\" }", respBody(t, response))
+	require.Equal(t, "{ \"response\": \"something went wrong\" }", heimdalltest.ResponseBody(t, response))
+	assert.Len(t, clock.Sleeps(), noOfRetries)
 }
 
 func TestHTTPClientGetReturnsNoErrorsIfRetrySucceeds(t *testing.T) {
@@ -346,11 +359,13 @@ func TestHTTPClientGetReturnsNoErrorsIfRetrySucceeds(t *testing.T) {
 	countWhenCallSucceeds := 2
 	backoffInterval := 1 * time.Millisecond
 	maximumJitterInterval := 1 * time.Millisecond
+	clock := heimdalltest.NewFakeClock(time.Now())
 
 	client := NewClient(
 		WithHTTPTimeout(10*time.Millisecond),
 		WithRetryCount(3),
 		WithRetrier(heimdall.NewRetrier(heimdall.NewConstantBackoff(backoffInterval, maximumJitterInterval))),
+		WithSleeper(clock),
 	)
 
 	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
@@ -371,7 +386,8 @@ func TestHTTPClientGetReturnsNoErrorsIfRetrySucceeds(t *testing.T) {
 
 	require.Equal(t, countWhenCallSucceeds+1, count)
 	require.Equal(t, http.StatusOK, response.StatusCode)
-	require.Equal(t, "{ \"response\": \"success\" }", respBody(t, response))
+	require.Equal(t, "{ \"response\": \"success\" }", heimdalltest.ResponseBody(t, response))
+	assert.Len(t, clock.Sleeps(), countWhenCallSucceeds)
 }
 
 func TestHTTPClientGetReturnsErrorOnClientCallFailure(t *testing.T) {
@@ -510,13 +526,211 @@ func TestCustomHTTPClientHeaderSuccess(t *testing.T) {
 	assert.Equal(t, "{ \"response\": \"ok\" }", string(body))
 }
 
-func respBody(t *testing.T, response *http.Response) string {
-	if response.Body != nil {
-		defer response.Body.Close()
+func TestHTTPClientAttemptsCarryAttemptContext(t *testing.T) {
+	var attempts []heimdall.Attempt
+
+	client := NewClient(
+		WithHTTPTimeout(10*time.Millisecond),
+		WithRetryCount(2),
+		WithHTTPClient(&attemptRecordingClient{attempts: &attempts}),
+	)
+
+	req, err := http.NewRequest(http.MethodGet, "http://localhost/", nil)
+	require.NoError(t, err)
+	ctx := req.Context()
+
+	_, err = client.Do(req)
+	require.Error(t, err)
+
+	assert.Equal(t, ctx, req.Context(), "the caller's request must not be modified")
+	require.Len(t, attempts, 3)
+	for i, attempt := range attempts {
+		assert.Equal(t, i, attempt.Number)
+		assert.Equal(t, attempts[0].RequestStart, attempt.RequestStart)
+		assert.False(t, attempt.Start.Before(attempt.RequestStart))
+	}
+}
+
+type attemptRecordingClient struct {
+	attempts *[]heimdall.Attempt
+}
+
+func (c *attemptRecordingClient) Do(request *http.Request) (*http.Response, error) {
+	attempt, _ := heimdall.AttemptFromContext(request.Context())
+	*c.attempts = append(*c.attempts, attempt)
+	return nil, errors.New("connection refused")
+}
+
+func TestHTTPClientMiddlewareRunsOncePerAttempt(t *testing.T) {
+	calls := 0
+	countingMiddleware := func(next heimdall.Doer) heimdall.Doer {
+		return heimdall.DoerFunc(func(request *http.Request) (*http.Response, error) {
+			calls++
+			return next.Do(request)
+		})
+	}
+
+	client := NewClient(
+		WithHTTPTimeout(10*time.Millisecond),
+		WithRetryCount(2),
+		WithMiddleware(countingMiddleware),
+	)
+
+	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
 	}
 
-	respBody, err := ioutil.ReadAll(response.Body)
-	require.NoError(t, err, "should not have failed to read response body")
+	server := httptest.NewServer(http.HandlerFunc(dummyHandler))
+	defer server.Close()
+
+	_, err := client.Get(server.URL, http.Header{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestHTTPClientRetriesWithClockAndSleeper(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := heimdalltest.NewFakeClock(start)
+	var attempts []heimdall.Attempt
+
+	client := NewClient(
+		WithHTTPClient(&attemptRecordingClient{attempts: &attempts}),
+		WithRetryCount(2),
+		WithRetrier(heimdall.NewRetrier(heimdall.NewExponentialBackoff(time.Second, time.Minute, 2, 0))),
+		WithClock(clock),
+		WithSleeper(clock),
+	)
+
+	_, err := client.Get("http://example.com", http.Header{})
+	require.Error(t, err)
+
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, clock.Sleeps(), "no backoff must follow the last attempt")
+	require.Len(t, attempts, 3)
+	assert.Equal(t, start, attempts[0].Start)
+	assert.Equal(t, start.Add(time.Second), attempts[1].Start)
+	assert.Equal(t, start.Add(3*time.Second), attempts[2].Start)
+	assert.Equal(t, 2*time.Second, attempts[2].Backoff)
+}
+
+func TestHTTPClientStopsRetryingWhenContextIsDoneDuringBackoff(t *testing.T) {
+	var attempts []heimdall.Attempt
+	client := NewClient(
+		WithHTTPClient(&attemptRecordingClient{attempts: &attempts}),
+		WithRetryCount(3),
+		WithRetrier(heimdall.NewRetrier(heimdall.NewConstantBackoff(time.Minute, 0))),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+
+	_, err = client.Do(request)
+	require.Error(t, err)
+
+	assert.Contains(t, err.Error(), context.DeadlineExceeded.Error())
+	assert.Len(t, attempts, 1)
+}
+
+func TestHTTPClientRetryCountFromContext(t *testing.T) {
+	var attempts []heimdall.Attempt
+	client := NewClient(
+		WithHTTPClient(&attemptRecordingClient{attempts: &attempts}),
+		WithRetryCount(1),
+		WithSleeper(heimdalltest.NewFakeClock(time.Now())),
+	)
+
+	request, err := http.NewRequestWithContext(heimdall.WithRetryCount(context.Background(), 4), http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+
+	_, err = client.Do(request)
+	require.Error(t, err)
+	assert.Len(t, attempts, 5)
+}
+
+func TestHTTPClientWithBaseURLAndDefaultHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/users/1", r.URL.Path)
+		assert.Equal(t, "heimdall", r.Header.Get("User-Agent"))
+		assert.Equal(t, "en", r.Header.Get("Accept-Language"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(
+		WithBaseURL(server.URL+"/v1"),
+		WithDefaultHeaders(http.Header{"User-Agent": {"heimdall"}, "Accept-Language": {"fr"}}),
+	)
+
+	response, err := client.Get("/users/1", http.Header{"Accept-Language": {"en"}})
+	require.NoError(t, err)
+	response.Body.Close()
+
+	_, err = client.Get("http://example.com/users/1", http.Header{})
+	assert.True(t, errors.Is(err, heimdall.ErrHostNotAllowed))
+}
+
+func TestHTTPClientConcurrentRequestsWithSharedHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "shared", r.Header.Get("X-Shared"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tagging := func(next heimdall.Doer) heimdall.Doer {
+		return heimdall.DoerFunc(func(request *http.Request) (*http.Response, error) {
+			request.Header.Set("X-Attempt", "1")
+			return next.Do(request)
+		})
+	}
+	client := NewClient(WithMiddleware(tagging))
+
+	headers := http.Header{"X-Shared": {"shared"}}
+	request, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	request.Header = headers
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			response, err := client.Get(server.URL, headers)
+			if assert.NoError(t, err) {
+				response.Body.Close()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			response, err := client.Do(request)
+			if assert.NoError(t, err) {
+				response.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, http.Header{"X-Shared": {"shared"}}, headers, "the caller's headers must not be modified")
+	assert.False(t, request.Close, "the caller's request must not be modified")
+}
+
+func TestHTTPClientWithBaseURLRefusesRedirectsToOtherHosts(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("the request must not reach another host, got X-Api-Key %q", r.Header.Get("X-Api-Key"))
+	}))
+	defer other.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+"/steal", http.StatusFound)
+	}))
+	defer server.Close()
+
+	client := NewClient(
+		WithBaseURL(server.URL),
+		WithDefaultHeaders(http.Header{"X-Api-Key": {"secret"}}),
+	)
 
-	return string(respBody)
+	_, err := client.Get("/users", http.Header{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), heimdall.ErrHostNotAllowed.Error())
 }
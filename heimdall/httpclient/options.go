@@ -1,6 +1,7 @@
 package httpclient
 
 import (
+	"net/http"
 	"time"
 
 	heimdall "github.com/go-light/httpclient/v3/heimdall"
@@ -36,3 +37,58 @@ func WithHTTPClient(client heimdall.Doer) Option {
 		c.client = client
 	}
 }
+
+// WithKeepAlive lets requests reuse connections kept alive by the transport
+// instead of closing them once the response is read
+func WithKeepAlive(keepAlive bool) Option {
+	return func(c *Client) {
+		c.keepAlive = keepAlive
+	}
+}
+
+// WithMiddleware wraps the http client with middlewares, the first one being
+// the outermost. Middlewares run inside the retry loop, once per attempt
+func WithMiddleware(middlewares ...heimdall.Middleware) Option {
+	return func(c *Client) {
+		c.middlewares = append(c.middlewares, middlewares...)
+	}
+}
+
+// WithClock sets the clock attempts are timed with, time.Now by default
+func WithClock(clock heimdall.Clock) Option {
+	return func(c *Client) {
+		c.clock = clock
+	}
+}
+
+// WithSleeper sets how the client waits between retries, a timer by default
+func WithSleeper(sleeper heimdall.Sleeper) Option {
+	return func(c *Client) {
+		c.sleeper = sleeper
+	}
+}
+
+// WithBaseURL resolves relative request URLs against baseURL, its path being
+// joined with theirs. Requests to other hosts then fail with
+// heimdall.ErrHostNotAllowed unless allowed WithAllowedHosts, redirects
+// included. A custom http client should use heimdall.CheckRedirect for that
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.defaults.BaseURL = baseURL
+	}
+}
+
+// WithDefaultHeaders sets headers sent with every request which doesn't set them
+func WithDefaultHeaders(headers http.Header) Option {
+	return func(c *Client) {
+		c.defaults.Header = headers.Clone()
+	}
+}
+
+// WithAllowedHosts restricts requests to the given hosts, on top of the one of
+// the base URL, failing the others with heimdall.ErrHostNotAllowed
+func WithAllowedHosts(hosts ...string) Option {
+	return func(c *Client) {
+		c.defaults.AllowedHosts = append(c.defaults.AllowedHosts, hosts...)
+	}
+}
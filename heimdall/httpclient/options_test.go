@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestOptionsAreSet(t *testing.T) {
@@ -24,12 +25,14 @@ func TestOptionsAreSet(t *testing.T) {
 		WithHTTPTimeout(httpTimeout),
 		WithRetrier(retrier),
 		WithRetryCount(noOfRetries),
+		WithKeepAlive(true),
 	)
 
 	assert.Equal(t, client, c.client)
 	assert.Equal(t, httpTimeout, c.timeout)
 	assert.Equal(t, retrier, c.retrier)
 	assert.Equal(t, noOfRetries, c.retryCount)
+	assert.True(t, c.keepAlive)
 }
 
 func TestOptionsHaveDefaults(t *testing.T) {
@@ -40,10 +43,13 @@ func TestOptionsHaveDefaults(t *testing.T) {
 
 	c := NewClient()
 
-	assert.Equal(t, http.DefaultClient, c.client)
+	require.IsType(t, &http.Client{}, c.client)
+	assert.Equal(t, httpTimeout, c.client.(*http.Client).Timeout)
+	assert.NotNil(t, c.client.(*http.Client).CheckRedirect, "redirects must be checked against the allowed hosts")
 	assert.Equal(t, httpTimeout, c.timeout)
 	assert.Equal(t, retrier, c.retrier)
 	assert.Equal(t, noOfRetries, c.retryCount)
+	assert.False(t, c.keepAlive)
 }
 
 func ExampleWithHTTPTimeout() {
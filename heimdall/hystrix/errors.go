@@ -0,0 +1,108 @@
+package hystrix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/afex/hystrix-go/hystrix"
+	"github.com/go-light/httpclient/v3/heimdall/breaker"
+)
+
+var (
+	// ErrTimeout is returned when the command took longer than the hystrix timeout
+	ErrTimeout = hystrix.ErrTimeout
+	// ErrCircuitOpen is returned when the hystrix circuit is open
+	ErrCircuitOpen = hystrix.ErrCircuitOpen
+	// ErrMaxConcurrency is returned when the command already runs the maximum number of concurrent requests
+	ErrMaxConcurrency = hystrix.ErrMaxConcurrency
+)
+
+// ServerError is returned, along with the response, when the last attempt got a 5xx response
+type ServerError struct {
+	StatusCode int
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("server returned 5xx status code: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// FallbackError is returned when the fallback function failed, it unwraps to
+// the error of the command
+type FallbackError struct {
+	// Err is the error returned by the fallback function
+	Err error
+	// Cause is the error of the command the fallback was called with
+	Cause error
+}
+
+func (e *FallbackError) Error() string {
+	return fmt.Sprintf("fallback failed with '%v'. run error was '%v'", e.Err, e.Cause)
+}
+
+// Unwrap returns the error of the command
+func (e *FallbackError) Unwrap() error {
+	return e.Cause
+}
+
+// Outcome tells how a request sent through the hystrix client ended
+type Outcome int
+
+const (
+	// OutcomeSuccess is a request which got a non 5xx response
+	OutcomeSuccess Outcome = iota
+	// OutcomeTimeout is a request which took longer than the hystrix timeout
+	OutcomeTimeout
+	// OutcomeCircuitOpen is a request rejected by an open circuit
+	OutcomeCircuitOpen
+	// OutcomeMaxConcurrency is a request rejected because too many were in flight
+	OutcomeMaxConcurrency
+	// OutcomeServerError is a request which got a 5xx response
+	OutcomeServerError
+	// OutcomeCanceled is a request whose context was canceled or timed out
+	OutcomeCanceled
+	// OutcomeError is a request which failed for any other reason
+	OutcomeError
+)
+
+// String returns the name of the outcome
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeSuccess:
+		return "success"
+	case OutcomeTimeout:
+		return "timeout"
+	case OutcomeCircuitOpen:
+		return "circuit_open"
+	case OutcomeMaxConcurrency:
+		return "max_concurrency"
+	case OutcomeServerError:
+		return "server_error"
+	case OutcomeCanceled:
+		return "canceled"
+	default:
+		return "error"
+	}
+}
+
+// OutcomeOf returns the outcome of a request from the error returned by the client
+func OutcomeOf(err error) Outcome {
+	var serverErr *ServerError
+	switch {
+	case err == nil:
+		return OutcomeSuccess
+	case errors.Is(err, ErrTimeout):
+		return OutcomeTimeout
+	case errors.Is(err, ErrCircuitOpen), errors.Is(err, breaker.ErrOpen), errors.Is(err, breaker.ErrTooManyProbes):
+		return OutcomeCircuitOpen
+	case errors.Is(err, ErrMaxConcurrency):
+		return OutcomeMaxConcurrency
+	case errors.As(err, &serverErr):
+		return OutcomeServerError
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return OutcomeCanceled
+	default:
+		return OutcomeError
+	}
+}
@@ -2,15 +2,19 @@ package hystrix
 
 import (
 	"bytes"
+	"context"
+	stderrors "errors"
 	"github.com/go-light/httpclient/v3/heimdall"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/afex/hystrix-go/hystrix"
 	metricCollector "github.com/afex/hystrix-go/hystrix/metric_collector"
 	"github.com/afex/hystrix-go/plugins"
+	"github.com/go-light/httpclient/v3/heimdall/breaker"
 	"github.com/go-light/httpclient/v3/heimdall/httpclient"
 	"github.com/pkg/errors"
 )
@@ -19,7 +23,9 @@ type fallbackFunc func(error) error
 
 // Client is the hystrix client implementation
 type Client struct {
-	client *httpclient.Client
+	client      *httpclient.Client
+	middlewares []heimdall.Middleware
+	doer        heimdall.Doer // client wrapped with middlewares
 
 	timeout                time.Duration
 	hystrixTimeout         time.Duration
@@ -30,8 +36,13 @@ type Client struct {
 	errorPercentThreshold  int
 	retryCount             int
 	retrier                heimdall.Retriable
+	sleeper                heimdall.Sleeper
+	defaults               heimdall.RequestDefaults
 	fallbackFunc           func(err error) error
+	fallbackResponseFunc   heimdall.FallbackFunc
 	statsD                 *plugins.StatsdCollectorConfig
+	breaker                *breaker.Breaker
+	breakerGroup           *breaker.Group
 }
 
 const (
@@ -48,7 +59,6 @@ const (
 )
 
 var _ heimdall.Client = (*Client)(nil)
-var err5xx = errors.New("server returned 5xx status code")
 
 // NewClient returns a new instance of hystrix Client
 func NewClient(opts ...Option) *Client {
@@ -62,12 +72,15 @@ func NewClient(opts ...Option) *Client {
 		requestVolumeThreshold: defaultRequestVolumeThreshold,
 		retryCount:             defaultHystrixRetryCount,
 		retrier:                heimdall.NewNoRetrier(),
+		sleeper:                heimdall.NewSystemSleeper(),
 	}
 
 	for _, opt := range opts {
 		opt(&client)
 	}
 
+	client.doer = heimdall.Chain(client.client, client.middlewares...)
+
 	if client.statsD != nil {
 		c, err := plugins.InitializeStatsdCollector(client.statsD)
 		if err != nil {
@@ -77,6 +90,11 @@ func NewClient(opts ...Option) *Client {
 		metricCollector.Registry.Register(c.NewStatsdCollector)
 	}
 
+	if client.breaker != nil || client.breakerGroup != nil {
+		// native breakers keeps its own state, nothing to register globally
+		return &client
+	}
+
 	hystrix.ConfigureCommand(client.hystrixCommandName, hystrix.CommandConfig{
 		Timeout:                durationToInt(client.hystrixTimeout, time.Millisecond),
 		MaxConcurrentRequests:  client.maxConcurrentRequests,
@@ -165,59 +183,188 @@ func (hhc *Client) Delete(url string, headers http.Header) (*http.Response, erro
 	return hhc.Do(request)
 }
 
-// Do makes an HTTP request with the native `http.Do` interface
+// Do makes an HTTP request with the native `http.Do` interface. When every
+// attempt got a 5xx response, the last one is returned along with a *ServerError
 func (hhc *Client) Do(request *http.Request) (*http.Response, error) {
-	var response *http.Response
-	var err error
-
-	var bodyReader *bytes.Reader
+	request, err := hhc.defaults.Apply(request)
+	if err != nil {
+		return nil, err
+	}
 
+	var reqData []byte
 	if request.Body != nil {
-		reqData, err := ioutil.ReadAll(request.Body)
+		reqData, err = ioutil.ReadAll(request.Body)
 		if err != nil {
 			return nil, err
 		}
-		bodyReader = bytes.NewReader(reqData)
-		request.Body = ioutil.NopCloser(bodyReader) // prevents closing the body between retries
+		request.Body.Close()
 	}
 
-	for i := 0; i <= hhc.retryCount; i++ {
+	ctx := request.Context()
+	var response *http.Response
+
+	retryCount := hhc.retryCount
+	if n, ok := heimdall.RetryCountFromContext(ctx); ok {
+		retryCount = n
+	}
+
+	for i := 0; i <= retryCount; i++ {
 		if response != nil {
 			response.Body.Close()
 		}
 
-		err = hystrix.Do(hhc.hystrixCommandName, func() error {
-			response, err = hhc.client.Do(request)
-			if bodyReader != nil {
-				// Reset the body reader after the request since at this point it's already read
-				// Note that it's safe to ignore the error here since the 0,0 position is always valid
-				_, _ = bodyReader.Seek(0, 0)
-			}
+		response, err = hhc.attempt(ctx, request, reqData)
+		if err == nil || i == retryCount {
+			break
+		}
+
+		if hhc.sleeper.Sleep(ctx, hhc.retrier.NextInterval(i)) != nil {
+			break
+		}
+	}
 
-			if err != nil {
-				return err
-			}
+	return response, err
+}
 
-			if response.StatusCode >= http.StatusInternalServerError {
-				return err5xx
-			}
-			return nil
-		}, hhc.fallbackFunc)
+// attempt runs one command for request and applies the fallback on failure
+func (hhc *Client) attempt(ctx context.Context, request *http.Request, reqData []byte) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	cmd := &command{doer: hhc.doer, cancel: cancel}
 
-		if err != nil {
-			backoffTime := hhc.retrier.NextInterval(i)
-			time.Sleep(backoffTime)
-			continue
-		}
+	err := hhc.execute(ctx, request, func(ctx context.Context) error {
+		return cmd.run(attemptRequest(ctx, request, reqData))
+	})
+	response := cmd.take()
 
-		break
+	var serverErr *ServerError
+	if err != nil && response != nil && !stderrors.As(err, &serverErr) {
+		// the response arrived after the command failed, e.g. timed out
+		response.Body.Close()
+		response = nil
 	}
 
-	if err == err5xx {
+	if err == nil {
 		return response, nil
 	}
+	return hhc.fallback(request, response, err)
+}
 
-	return response, err
+// execute runs the command through the native circuit breaker of request when
+// one is set, through the hystrix-go command registry otherwise
+func (hhc *Client) execute(ctx context.Context, request *http.Request, run func(context.Context) error) error {
+	cb := hhc.breaker
+	if hhc.breakerGroup != nil {
+		cb = hhc.breakerGroup.For(request)
+	}
+	if cb == nil {
+		return hystrix.DoC(ctx, hhc.hystrixCommandName, run, nil)
+	}
+
+	done, err := cb.Allow()
+	if err != nil {
+		return err
+	}
+	err = run(ctx)
+	done(err != nil)
+	return err
+}
+
+// fallback hands the failed request to the fallback function, if any
+func (hhc *Client) fallback(request *http.Request, response *http.Response, err error) (*http.Response, error) {
+	switch {
+	case hhc.fallbackResponseFunc != nil:
+		fallbackResponse, fallbackErr := hhc.fallbackResponseFunc(request, err)
+		if fallbackErr != nil {
+			return response, &FallbackError{Err: fallbackErr, Cause: err}
+		}
+		if response != nil {
+			response.Body.Close()
+		}
+		return fallbackResponse, nil
+	case hhc.fallbackFunc != nil:
+		if fallbackErr := hhc.fallbackFunc(err); fallbackErr != nil {
+			return response, &FallbackError{Err: fallbackErr, Cause: err}
+		}
+		return response, nil
+	default:
+		return response, err
+	}
+}
+
+// attemptRequest returns a copy of request for one attempt, with its own
+// context, headers and body so an abandoned attempt never races the next one
+func attemptRequest(ctx context.Context, request *http.Request, reqData []byte) *http.Request {
+	attemptRequest := request.WithContext(ctx)
+	attemptRequest.Header = request.Header.Clone()
+	if reqData != nil {
+		attemptRequest.Body = ioutil.NopCloser(bytes.NewReader(reqData))
+		attemptRequest.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(reqData)), nil
+		}
+	}
+	return attemptRequest
+}
+
+// command runs a single attempt. The response belongs to the command until
+// taken, one arriving once the command was given up on, e.g. after a hystrix
+// timeout, is closed instead of being handed over
+type command struct {
+	doer   heimdall.Doer
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	response  *http.Response
+	abandoned bool
+}
+
+func (c *command) run(request *http.Request) error {
+	response, err := c.doer.Do(request)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.abandoned {
+		response.Body.Close()
+		return nil
+	}
+	c.response = response
+
+	if response.StatusCode >= http.StatusInternalServerError {
+		return &ServerError{StatusCode: response.StatusCode}
+	}
+	return nil
+}
+
+// take gives the command up and returns its response, if any. The context of
+// the attempt is canceled once the response body is closed, right away when
+// there is no response
+func (c *command) take() *http.Response {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.abandoned = true
+	response := c.response
+	c.response = nil
+
+	if response == nil {
+		c.cancel()
+		return nil
+	}
+	response.Body = &cancelOnClose{ReadCloser: response.Body, cancel: c.cancel}
+	return response
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
 }
 
 // AddPlugin Adds plugin to client
@@ -2,11 +2,16 @@ package hystrix
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"github.com/go-light/httpclient/v3/heimdall"
+	"github.com/go-light/httpclient/v3/heimdall/breaker"
+	"github.com/go-light/httpclient/v3/heimdall/heimdalltest"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -91,7 +96,7 @@ func TestHystrixHTTPClientGetSuccess(t *testing.T) {
 	require.NoError(t, err, "should not have failed to make a GET request")
 
 	assert.Equal(t, http.StatusOK, response.StatusCode)
-	assert.Equal(t, "{ \"response\": \"ok\" }", respBody(t, response))
+	assert.Equal(t, "{ \"response\": \"ok\" }", heimdalltest.ResponseBody(t, response))
 }
 
 func TestHystrixHTTPClientPostSuccess(t *testing.T) {
@@ -134,7 +139,7 @@ func TestHystrixHTTPClientPostSuccess(t *testing.T) {
 	require.NoError(t, err, "should not have failed to make a POST request")
 
 	assert.Equal(t, http.StatusOK, response.StatusCode)
-	assert.Equal(t, "{ \"response\": \"ok\" }", respBody(t, response))
+	assert.Equal(t, "{ \"response\": \"ok\" }", heimdalltest.ResponseBody(t, response))
 }
 
 func TestHystrixHTTPClientDeleteSuccess(t *testing.T) {
@@ -168,7 +173,7 @@ func TestHystrixHTTPClientDeleteSuccess(t *testing.T) {
 	require.NoError(t, err, "should not have failed to make a DELETE request")
 
 	assert.Equal(t, http.StatusOK, response.StatusCode)
-	assert.Equal(t, "{ \"response\": \"ok\" }", respBody(t, response))
+	assert.Equal(t, "{ \"response\": \"ok\" }", heimdalltest.ResponseBody(t, response))
 }
 
 func TestHystrixHTTPClientPutSuccess(t *testing.T) {
@@ -211,7 +216,7 @@ func TestHystrixHTTPClientPutSuccess(t *testing.T) {
 	require.NoError(t, err, "should not have failed to make a PUT request")
 
 	assert.Equal(t, http.StatusOK, response.StatusCode)
-	assert.Equal(t, "{ \"response\": \"ok\" }", respBody(t, response))
+	assert.Equal(t, "{ \"response\": \"ok\" }", heimdalltest.ResponseBody(t, response))
 }
 
 func TestHystrixHTTPClientPatchSuccess(t *testing.T) {
@@ -254,12 +259,13 @@ func TestHystrixHTTPClientPatchSuccess(t *testing.T) {
 	require.NoError(t, err, "should not have failed to make a PATCH request")
 
 	assert.Equal(t, http.StatusOK, response.StatusCode)
-	assert.Equal(t, "{ \"response\": \"ok\" }", respBody(t, response))
+	assert.Equal(t, "{ \"response\": \"ok\" }", heimdalltest.ResponseBody(t, response))
 }
 
 func TestHystrixHTTPClientRetriesGetOnFailure(t *testing.T) {
 	backoffInterval := 1 * time.Millisecond
 	maximumJitterInterval := 1 * time.Millisecond
+	clock := heimdalltest.NewFakeClock(time.Now())
 
 	client := NewClient(
 		WithHTTPTimeout(10*time.Millisecond),
@@ -271,18 +277,21 @@ func TestHystrixHTTPClientRetriesGetOnFailure(t *testing.T) {
 		WithRequestVolumeThreshold(10),
 		WithRetryCount(3),
 		WithRetrier(heimdall.NewRetrier(heimdall.NewConstantBackoff(backoffInterval, maximumJitterInterval))),
+		WithSleeper(clock),
 	)
 
 	response, err := client.Get("url_doesnt_exist", http.Header{})
 
 	assert.Contains(t, err.Error(), "unsupported protocol scheme")
 	assert.Nil(t, response)
+	assert.Len(t, clock.Sleeps(), 3)
 }
 
 func TestHystrixHTTPClientRetriesGetOnFailure5xx(t *testing.T) {
 	count := 0
 	backoffInterval := 1 * time.Millisecond
 	maximumJitterInterval := 1 * time.Millisecond
+	clock := heimdalltest.NewFakeClock(time.Now())
 
 	client := NewClient(
 		WithHTTPTimeout(10*time.Millisecond),
@@ -294,6 +303,7 @@ func TestHystrixHTTPClientRetriesGetOnFailure5xx(t *testing.T) {
 		WithRequestVolumeThreshold(10),
 		WithRetryCount(3),
 		WithRetrier(heimdall.NewRetrier(heimdall.NewConstantBackoff(backoffInterval, maximumJitterInterval))),
+		WithSleeper(clock),
 	)
 
 	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
@@ -306,12 +316,15 @@ func TestHystrixHTTPClientRetriesGetOnFailure5xx(t *testing.T) {
 	defer server.Close()
 
 	response, err := client.Get(server.URL, http.Header{})
-	require.NoError(t, err)
+	assert.Equal(t, &ServerError{StatusCode: http.StatusInternalServerError}, err)
+	assert.Equal(t, OutcomeServerError, OutcomeOf(err))
+	require.NotNil(t, response)
 
 	assert.Equal(t, 4, count)
 
 	assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
-	assert.Equal(t, "{ \"response\": \"something went wrong\" }", respBody(t, response))
+	assert.Equal(t, "{ \"response\": \"something went wrong\" }", heimdalltest.ResponseBody(t, response))
+	assert.Len(t, clock.Sleeps(), 3)
 }
 
 func BenchmarkHystrixHTTPClientRetriesGetOnFailure(b *testing.B) {
@@ -347,6 +360,7 @@ func TestHystrixHTTPClientRetriesPostOnFailure(t *testing.T) {
 	count := 0
 	backoffInterval := 1 * time.Millisecond
 	maximumJitterInterval := 1 * time.Millisecond
+	clock := heimdalltest.NewFakeClock(time.Now())
 
 	client := NewClient(
 		WithHTTPTimeout(50*time.Millisecond),
@@ -358,6 +372,7 @@ func TestHystrixHTTPClientRetriesPostOnFailure(t *testing.T) {
 		WithRequestVolumeThreshold(20),
 		WithRetryCount(3),
 		WithRetrier(heimdall.NewRetrier(heimdall.NewConstantBackoff(backoffInterval, maximumJitterInterval))),
+		WithSleeper(clock),
 	)
 
 	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
@@ -370,11 +385,13 @@ func TestHystrixHTTPClientRetriesPostOnFailure(t *testing.T) {
 	defer server.Close()
 
 	response, err := client.Post(server.URL, strings.NewReader("a=1&b=2"), http.Header{})
-	require.NoError(t, err)
+	assert.Equal(t, &ServerError{StatusCode: http.StatusInternalServerError}, err)
+	require.NotNil(t, response)
 
 	assert.Equal(t, 4, count)
 	assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
-	assert.JSONEq(t, `{ "response": "something went wrong" }`, respBody(t, response))
+	assert.JSONEq(t, `{ "response": "something went wrong" }`, heimdalltest.ResponseBody(t, response))
+	assert.Len(t, clock.Sleeps(), 3)
 }
 
 func BenchmarkHystrixHTTPClientRetriesPostOnFailure(b *testing.B) {
@@ -517,19 +534,6 @@ func TestCustomHystrixHTTPClientDoSuccess(t *testing.T) {
 	assert.Equal(t, "{ \"response\": \"ok\" }", string(body))
 }
 
-func respBody(t *testing.T, response *http.Response) string {
-	if response.Body != nil {
-		defer func() {
-			_ = response.Body.Close()
-		}()
-	}
-
-	respBody, err := ioutil.ReadAll(response.Body)
-	require.NoError(t, err, "should not have failed to read response body")
-
-	return string(respBody)
-}
-
 func TestDurationToInt(t *testing.T) {
 	t.Run("1sec should return 1 when unit is second", func(t *testing.T) {
 		timeout := 1 * time.Second
@@ -545,3 +549,322 @@ func TestDurationToInt(t *testing.T) {
 		assert.Equal(t, 30000, timeoutInMs)
 	})
 }
+
+func TestHystrixHTTPClientWithNativeCircuitBreaker(t *testing.T) {
+	count := 0
+	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
+		count++
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(dummyHandler))
+	defer server.Close()
+
+	failing := NewClient(
+		WithHTTPTimeout(50*time.Millisecond),
+		WithCircuitBreaker(breaker.New(breaker.WithMinimumRequests(2))),
+	)
+	healthy := NewClient(
+		WithHTTPTimeout(50*time.Millisecond),
+		WithCircuitBreaker(breaker.New(breaker.WithMinimumRequests(2))),
+	)
+
+	for i := 0; i < 2; i++ {
+		response, err := failing.Get(server.URL, http.Header{})
+		assert.Equal(t, OutcomeServerError, OutcomeOf(err))
+		assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
+	}
+
+	_, err := failing.Get(server.URL, http.Header{})
+	assert.Equal(t, breaker.ErrOpen, err)
+	assert.Equal(t, 2, count)
+
+	response, err := healthy.Get(server.URL, http.Header{})
+	assert.Equal(t, OutcomeServerError, OutcomeOf(err), "clients with their own breakers must not share state")
+	assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
+}
+
+func TestHystrixHTTPClientWithCircuitBreakerGroup(t *testing.T) {
+	failingServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failingServer.Close()
+
+	healthyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthyServer.Close()
+
+	client := NewClient(
+		WithHTTPTimeout(50*time.Millisecond),
+		WithCircuitBreakerGroup(breaker.NewGroup(breaker.WithBreakerOptions(breaker.WithMinimumRequests(1)))),
+	)
+
+	_, err := client.Get(failingServer.URL, http.Header{})
+	assert.Equal(t, OutcomeServerError, OutcomeOf(err))
+	_, err = client.Get(failingServer.URL, http.Header{})
+	assert.Equal(t, breaker.ErrOpen, err)
+
+	response, err := client.Get(healthyServer.URL, http.Header{})
+	require.NoError(t, err, "a failing host must not trip calls to a healthy one")
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
+
+func TestHystrixHTTPClientReturnsFallbackResponse(t *testing.T) {
+	var fallbackRequest *http.Request
+	client := NewClient(
+		WithHTTPTimeout(10*time.Millisecond),
+		WithCommandName("some_fallback_response_command"),
+		WithHystrixTimeout(10*time.Millisecond),
+		WithFallbackResponseFunc(func(request *http.Request, err error) (*http.Response, error) {
+			fallbackRequest = request
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(strings.NewReader("cached")),
+			}, nil
+		}),
+	)
+
+	response, err := client.Get("http://foobar.example", http.Header{})
+	require.NoError(t, err)
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "cached", string(body))
+	require.NotNil(t, fallbackRequest)
+	assert.Equal(t, "foobar.example", fallbackRequest.URL.Host)
+}
+
+func TestHystrixHTTPClientReturnsFallbackResponseError(t *testing.T) {
+	client := NewClient(
+		WithHTTPTimeout(10*time.Millisecond),
+		WithCommandName("some_fallback_response_command"),
+		WithHystrixTimeout(10*time.Millisecond),
+		WithFallbackResponseFunc(func(request *http.Request, err error) (*http.Response, error) {
+			return nil, errors.New("no cached response")
+		}),
+	)
+
+	_, err := client.Get("http://foobar.example", http.Header{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no cached response")
+}
+
+func TestHystrixHTTPClientReturnsTimeoutOutcome(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewClient(
+		WithHTTPTimeout(time.Second),
+		WithCommandName("some_timeout_command"),
+		WithHystrixTimeout(10*time.Millisecond),
+		WithRequestVolumeThreshold(100),
+	)
+
+	response, err := client.Get(server.URL, http.Header{})
+	assert.Nil(t, response)
+	assert.Equal(t, ErrTimeout, err)
+	assert.Equal(t, OutcomeTimeout, OutcomeOf(err))
+}
+
+func TestHystrixHTTPClientHonoursContext(t *testing.T) {
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	client := NewClient(
+		WithHTTPTimeout(time.Second),
+		WithCommandName("some_context_command"),
+		WithHystrixTimeout(time.Second),
+		WithRequestVolumeThreshold(100),
+		WithRetryCount(3),
+		WithRetrier(heimdall.NewRetrier(heimdall.NewConstantBackoff(time.Second, time.Millisecond))),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+
+	start := time.Now()
+	response, err := client.Do(request)
+	require.NotNil(t, response)
+	response.Body.Close()
+
+	assert.Equal(t, OutcomeServerError, OutcomeOf(err))
+	assert.Equal(t, 1, count, "no retry must be made once the context is done")
+	assert.True(t, time.Since(start) < time.Second, "the backoff must not outlive the context")
+}
+
+func TestHystrixHTTPClientFallbackErrorUnwrapsToCause(t *testing.T) {
+	client := NewClient(
+		WithCircuitBreaker(breaker.New()),
+		WithFallbackFunc(func(err error) error {
+			return errors.New("no fallback")
+		}),
+	)
+
+	_, err := client.Get("url_doesnt_exist", http.Header{})
+	var fallbackErr *FallbackError
+	require.True(t, errors.As(err, &fallbackErr))
+	assert.EqualError(t, fallbackErr.Err, "no fallback")
+	assert.Contains(t, fallbackErr.Cause.Error(), "unsupported protocol scheme")
+	assert.Equal(t, OutcomeError, OutcomeOf(err))
+}
+
+func TestOutcomeOf(t *testing.T) {
+	assert.Equal(t, OutcomeSuccess, OutcomeOf(nil))
+	assert.Equal(t, OutcomeCircuitOpen, OutcomeOf(ErrCircuitOpen))
+	assert.Equal(t, OutcomeCircuitOpen, OutcomeOf(breaker.ErrOpen))
+	assert.Equal(t, OutcomeMaxConcurrency, OutcomeOf(ErrMaxConcurrency))
+	assert.Equal(t, OutcomeCanceled, OutcomeOf(context.Canceled))
+	assert.Equal(t, "circuit_open", OutcomeCircuitOpen.String())
+}
+
+func TestHystrixHTTPClientMiddlewareRunsWithinCommand(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	slow := func(next heimdall.Doer) heimdall.Doer {
+		return heimdall.DoerFunc(func(request *http.Request) (*http.Response, error) {
+			select {
+			case <-time.After(time.Second):
+			case <-request.Context().Done():
+				return nil, request.Context().Err()
+			}
+			return next.Do(request)
+		})
+	}
+
+	client := NewClient(
+		WithHTTPTimeout(time.Second),
+		WithCommandName("some_middleware_command"),
+		WithHystrixTimeout(10*time.Millisecond),
+		WithRequestVolumeThreshold(100),
+		WithMiddleware(slow),
+	)
+
+	_, err := client.Get(server.URL, http.Header{})
+	assert.Equal(t, OutcomeTimeout, OutcomeOf(err), "the hystrix timeout must cover the middlewares")
+}
+
+func TestHystrixHTTPClientRetriesWithSleeper(t *testing.T) {
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	clock := heimdalltest.NewFakeClock(time.Now())
+	client := NewClient(
+		WithCircuitBreaker(breaker.New()),
+		WithRetryCount(2),
+		WithRetrier(heimdall.NewRetrier(heimdall.NewConstantBackoff(time.Hour, 0))),
+		WithClock(clock),
+		WithSleeper(clock),
+	)
+
+	response, err := client.Get(server.URL, http.Header{})
+	require.NotNil(t, response)
+	response.Body.Close()
+
+	assert.Equal(t, OutcomeServerError, OutcomeOf(err))
+	assert.Equal(t, 3, count)
+	assert.Equal(t, []time.Duration{time.Hour, time.Hour}, clock.Sleeps())
+}
+
+func TestHystrixHTTPClientWithBaseURLAndDefaultHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/users/1", r.URL.Path)
+		assert.Equal(t, "heimdall", r.Header.Get("User-Agent"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient(
+		WithCircuitBreaker(breaker.New()),
+		WithBaseURL(server.URL+"/v1"),
+		WithDefaultHeaders(http.Header{"User-Agent": {"heimdall"}}),
+	)
+
+	response, err := client.Get("/users/1", http.Header{})
+	require.NoError(t, err)
+	response.Body.Close()
+
+	_, err = client.Get("http://example.com/users/1", http.Header{})
+	assert.True(t, errors.Is(err, heimdall.ErrHostNotAllowed))
+}
+
+func TestHystrixHTTPClientConcurrentRequestsWithSharedHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "shared", r.Header.Get("X-Shared"))
+		assert.Equal(t, "heimdall", r.Header.Get("User-Agent"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tagging := func(next heimdall.Doer) heimdall.Doer {
+		return heimdall.DoerFunc(func(request *http.Request) (*http.Response, error) {
+			request.Header.Set("X-Attempt", "1")
+			return next.Do(request)
+		})
+	}
+	client := NewClient(
+		WithCircuitBreaker(breaker.New()),
+		WithDefaultHeaders(http.Header{"User-Agent": {"heimdall"}}),
+		WithMiddleware(tagging),
+	)
+
+	headers := http.Header{"X-Shared": {"shared"}}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := client.Post(server.URL, strings.NewReader("a=1"), headers)
+			if assert.NoError(t, err) {
+				response.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, http.Header{"X-Shared": {"shared"}}, headers, "the caller's headers must not be modified")
+}
+
+func TestHystrixHTTPClientWithBaseURLRefusesRedirectsToOtherHosts(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("the request must not reach another host, got X-Api-Key %q", r.Header.Get("X-Api-Key"))
+	}))
+	defer other.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+"/steal", http.StatusFound)
+	}))
+	defer server.Close()
+
+	client := NewClient(
+		WithCircuitBreaker(breaker.New()),
+		WithBaseURL(server.URL),
+		WithDefaultHeaders(http.Header{"X-Api-Key": {"secret"}}),
+	)
+
+	_, err := client.Get("/users", http.Header{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), heimdall.ErrHostNotAllowed.Error())
+}
@@ -2,9 +2,11 @@ package hystrix
 
 import (
 	"github.com/go-light/httpclient/v3/heimdall"
+	"net/http"
 	"time"
 
 	"github.com/afex/hystrix-go/plugins"
+	"github.com/go-light/httpclient/v3/heimdall/breaker"
 	"github.com/go-light/httpclient/v3/heimdall/httpclient"
 )
 
@@ -67,6 +69,15 @@ func WithFallbackFunc(fn fallbackFunc) Option {
 	}
 }
 
+// WithFallbackResponseFunc sets a fallback function which may return a
+// synthetic response for the failed request, handed to the caller with a nil
+// error. It takes precedence over WithFallbackFunc
+func WithFallbackResponseFunc(fn heimdall.FallbackFunc) Option {
+	return func(c *Client) {
+		c.fallbackResponseFunc = fn
+	}
+}
+
 // WithRetryCount sets the retry count for the Client
 func WithRetryCount(retryCount int) Option {
 	return func(c *Client) {
@@ -81,6 +92,21 @@ func WithRetrier(retrier heimdall.Retriable) Option {
 	}
 }
 
+// WithClock sets the clock attempts are timed with, time.Now by default
+func WithClock(clock heimdall.Clock) Option {
+	return func(c *Client) {
+		opt := httpclient.WithClock(clock)
+		opt(c.client)
+	}
+}
+
+// WithSleeper sets how the client waits between retries, a timer by default
+func WithSleeper(sleeper heimdall.Sleeper) Option {
+	return func(c *Client) {
+		c.sleeper = sleeper
+	}
+}
+
 // WithHTTPClient sets a custom http client for hystrix client
 func WithHTTPClient(client heimdall.Doer) Option {
 	return func(c *Client) {
@@ -89,9 +115,62 @@ func WithHTTPClient(client heimdall.Doer) Option {
 	}
 }
 
+// WithMiddleware wraps the http client with middlewares, the first one being
+// the outermost. Middlewares run within the hystrix command, once per attempt,
+// so the circuit and timeout observe what they do
+func WithMiddleware(middlewares ...heimdall.Middleware) Option {
+	return func(c *Client) {
+		c.middlewares = append(c.middlewares, middlewares...)
+	}
+}
+
 // WithStatsDCollector exports hystrix metrics to a statsD backend
 func WithStatsDCollector(addr, prefix string) Option {
 	return func(c *Client) {
 		c.statsD = &plugins.StatsdCollectorConfig{StatsdAddr: addr, Prefix: prefix}
 	}
 }
+
+// WithCircuitBreaker makes the client use the given native circuit breaker
+// instead of the process wide hystrix-go command registry, so clients never
+// share or overwrite each other's settings. The hystrix specific options,
+// timeout included, are then ignored
+func WithCircuitBreaker(b *breaker.Breaker) Option {
+	return func(c *Client) {
+		c.breaker = b
+	}
+}
+
+// WithCircuitBreakerGroup makes the client use one native circuit breaker per
+// key of the group, by default per host, so a failing host doesn't trip calls
+// to healthy ones. It takes precedence over WithCircuitBreaker
+func WithCircuitBreakerGroup(g *breaker.Group) Option {
+	return func(c *Client) {
+		c.breakerGroup = g
+	}
+}
+
+// WithBaseURL resolves relative request URLs against baseURL, its path being
+// joined with theirs. Requests to other hosts then fail with
+// heimdall.ErrHostNotAllowed unless allowed WithAllowedHosts, redirects
+// included. A custom http client should use heimdall.CheckRedirect for that
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.defaults.BaseURL = baseURL
+	}
+}
+
+// WithDefaultHeaders sets headers sent with every request which doesn't set them
+func WithDefaultHeaders(headers http.Header) Option {
+	return func(c *Client) {
+		c.defaults.Header = headers.Clone()
+	}
+}
+
+// WithAllowedHosts restricts requests to the given hosts, on top of the one of
+// the base URL, failing the others with heimdall.ErrHostNotAllowed
+func WithAllowedHosts(hosts ...string) Option {
+	return func(c *Client) {
+		c.defaults.AllowedHosts = append(c.defaults.AllowedHosts, hosts...)
+	}
+}
@@ -0,0 +1,25 @@
+package heimdall
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DefaultKeyHeaders are the request headers shared responses are keyed on by
+// default, so they are never shared between callers with different credentials
+var DefaultKeyHeaders = []string{"Authorization", "Cookie", "Accept"}
+
+// RequestKey identifies request by its method, URL and the values of the given headers
+func RequestKey(request *http.Request, headers []string) string {
+	var key strings.Builder
+	key.WriteString(request.Method)
+	key.WriteByte(' ')
+	key.WriteString(request.URL.String())
+	for _, name := range headers {
+		key.WriteByte('\n')
+		key.WriteString(name)
+		key.WriteByte(':')
+		key.WriteString(strings.Join(request.Header.Values(name), ","))
+	}
+	return key.String()
+}
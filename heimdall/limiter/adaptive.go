@@ -0,0 +1,155 @@
+package limiter
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+)
+
+// ErrLimitExceeded is returned when the adaptive limit of in flight requests is reached
+var ErrLimitExceeded = errors.New("limiter: concurrency limit exceeded")
+
+// Algorithm computes the concurrency limit of an AdaptiveLimiter from the
+// outcome of every call. Implementations don't need to be safe for concurrent
+// use, the limiter serializes the calls
+type Algorithm interface {
+	// Limit returns the current limit
+	Limit() int
+	// OnSample records a call which took rtt, with inFlight calls in flight
+	// when it started, and returns the new limit. dropped tells the call
+	// failed or was rejected downstream, a sign of overload
+	OnSample(rtt time.Duration, inFlight int, dropped bool) int
+}
+
+// AdaptiveLimiter limits how many requests are in flight at once, adjusting the
+// limit from the latency and errors of the calls it lets through. It is safe for
+// concurrent use
+type AdaptiveLimiter struct {
+	algorithm     Algorithm
+	isDropped     func(*http.Response, error) bool
+	onLimitChange func(limit int)
+	clock         heimdall.Clock
+
+	mu       sync.Mutex
+	inFlight int
+	limit    int
+}
+
+// AdaptiveOption represents the adaptive limiter options
+type AdaptiveOption func(*AdaptiveLimiter)
+
+// WithDroppedFunc sets how Wrap decides a call was dropped, by default transport
+// errors, 429 and 5xx responses are
+func WithDroppedFunc(isDropped func(*http.Response, error) bool) AdaptiveOption {
+	return func(l *AdaptiveLimiter) {
+		l.isDropped = isDropped
+	}
+}
+
+// WithOnLimitChange sets a callback run with the new limit every time it
+// changes, for example to export it as a metric
+func WithOnLimitChange(onLimitChange func(limit int)) AdaptiveOption {
+	return func(l *AdaptiveLimiter) {
+		l.onLimitChange = onLimitChange
+	}
+}
+
+// WithAdaptiveClock sets the clock used to measure calls
+func WithAdaptiveClock(clock heimdall.Clock) AdaptiveOption {
+	return func(l *AdaptiveLimiter) {
+		l.clock = clock
+	}
+}
+
+// NewAdaptiveLimiter returns a new limiter driven by algorithm
+func NewAdaptiveLimiter(algorithm Algorithm, opts ...AdaptiveOption) *AdaptiveLimiter {
+	l := &AdaptiveLimiter{
+		algorithm: algorithm,
+		isDropped: isOverloaded,
+		clock:     heimdall.NewSystemClock(),
+		limit:     algorithm.Limit(),
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+func isOverloaded(response *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return response != nil && (response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= http.StatusInternalServerError)
+}
+
+// Limit returns the current concurrency limit
+func (l *AdaptiveLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// InFlight returns the number of calls let through and not done yet
+func (l *AdaptiveLimiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
+
+// Acquire lets a call through unless the limit is reached. On success the
+// caller must report the outcome of the call through done
+func (l *AdaptiveLimiter) Acquire() (done func(dropped bool), err error) {
+	l.mu.Lock()
+	if l.inFlight >= l.limit {
+		l.mu.Unlock()
+		return nil, ErrLimitExceeded
+	}
+	l.inFlight++
+	inFlight := l.inFlight
+	l.mu.Unlock()
+
+	start := l.clock.Now()
+	var once sync.Once
+	return func(dropped bool) {
+		once.Do(func() {
+			l.onSample(l.clock.Now().Sub(start), inFlight, dropped)
+		})
+	}, nil
+}
+
+// Wrap returns a Doer letting requests through next within the limit, the
+// call being measured until the response headers are received, so it can be
+// used as a heimdall.Middleware
+func (l *AdaptiveLimiter) Wrap(next heimdall.Doer) heimdall.Doer {
+	return heimdall.DoerFunc(func(request *http.Request) (*http.Response, error) {
+		done, err := l.Acquire()
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := next.Do(request)
+		done(l.isDropped(response, err))
+		return response, err
+	})
+}
+
+func (l *AdaptiveLimiter) onSample(rtt time.Duration, inFlight int, dropped bool) {
+	l.mu.Lock()
+	l.inFlight--
+	previous := l.limit
+	l.limit = l.algorithm.OnSample(rtt, inFlight, dropped)
+	if l.limit < 1 {
+		l.limit = 1
+	}
+	limit := l.limit
+	l.mu.Unlock()
+
+	if limit != previous && l.onLimitChange != nil {
+		l.onLimitChange(limit)
+	}
+}
@@ -0,0 +1,152 @@
+package limiter
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+	"github.com/go-light/httpclient/v3/heimdall/heimdalltest"
+	"github.com/go-light/httpclient/v3/heimdall/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAIMDIncreasesWhenUsedAndBacksOffOnDrop(t *testing.T) {
+	algorithm := NewAIMD(10, 1, 12, 0.5, 100*time.Millisecond)
+
+	assert.Equal(t, 10, algorithm.OnSample(time.Millisecond, 1, false), "an unused limit must not grow")
+	assert.Equal(t, 11, algorithm.OnSample(time.Millisecond, 5, false))
+	assert.Equal(t, 12, algorithm.OnSample(time.Millisecond, 11, false))
+	assert.Equal(t, 12, algorithm.OnSample(time.Millisecond, 12, false), "the limit must not exceed the maximum")
+	assert.Equal(t, 6, algorithm.OnSample(time.Millisecond, 12, true))
+	assert.Equal(t, 3, algorithm.OnSample(time.Second, 1, false), "a call slower than the timeout must back off")
+	assert.Equal(t, 1, algorithm.OnSample(time.Millisecond, 1, true))
+	assert.Equal(t, 1, algorithm.OnSample(time.Millisecond, 1, true), "the limit must not go below the minimum")
+}
+
+func TestGradientGrowsWhileLatencyIsSteady(t *testing.T) {
+	algorithm := NewGradient(10, 1, 100, 1)
+
+	limit := algorithm.Limit()
+	for i := 0; i < 5; i++ {
+		limit = algorithm.OnSample(10*time.Millisecond, limit, false)
+	}
+	assert.True(t, limit > 10, "limit %d should have grown", limit)
+}
+
+func TestGradientGrowsAndRecoversWithDefaultSmoothing(t *testing.T) {
+	algorithm := NewGradient(10, 1, 100, 0)
+
+	limit := algorithm.Limit()
+	for i := 0; i < 100; i++ {
+		limit = algorithm.OnSample(10*time.Millisecond, limit, false)
+	}
+	assert.True(t, limit > 20, "limit %d should have grown", limit)
+
+	dropped := algorithm.OnSample(10*time.Millisecond, limit, true)
+	assert.True(t, dropped < limit, "limit %d should be below %d", dropped, limit)
+
+	limit = dropped
+	for i := 0; i < 100; i++ {
+		limit = algorithm.OnSample(10*time.Millisecond, limit, false)
+	}
+	assert.True(t, limit > dropped, "limit %d should have recovered from %d", limit, dropped)
+}
+
+func TestGradientShrinksAsLatencyIncreases(t *testing.T) {
+	algorithm := NewGradient(50, 1, 100, 1)
+
+	algorithm.OnSample(10*time.Millisecond, 50, false)
+	before := algorithm.Limit()
+	after := algorithm.OnSample(time.Second, before, false)
+	assert.True(t, after < before, "limit %d should be below %d", after, before)
+
+	dropped := algorithm.OnSample(10*time.Millisecond, after, true)
+	assert.Equal(t, after/2, dropped)
+}
+
+func TestGradientWithoutMaximumLimit(t *testing.T) {
+	algorithm := NewGradient(10, 1, 0, 1)
+
+	limit := algorithm.Limit()
+	for i := 0; i < 5; i++ {
+		limit = algorithm.OnSample(10*time.Millisecond, limit, false)
+	}
+	assert.True(t, limit > 10, "a max limit of 0 means no maximum, limit %d should have grown", limit)
+}
+
+func TestGradientKeepsLimitWhileUnused(t *testing.T) {
+	algorithm := NewGradient(10, 1, 100, 1)
+
+	assert.Equal(t, 10, algorithm.OnSample(10*time.Millisecond, 1, false))
+}
+
+func TestAdaptiveLimiterRejectsOverLimit(t *testing.T) {
+	l := NewAdaptiveLimiter(NewAIMD(1, 1, 10, 0.5, 0))
+
+	done, err := l.Acquire()
+	require.NoError(t, err)
+	assert.Equal(t, 1, l.InFlight())
+
+	_, err = l.Acquire()
+	assert.Equal(t, ErrLimitExceeded, err)
+
+	done(false)
+	done(false)
+	assert.Equal(t, 0, l.InFlight(), "done must be idempotent")
+	assert.Equal(t, 2, l.Limit())
+}
+
+func TestAdaptiveLimiterReportsLimitChanges(t *testing.T) {
+	var limits []int
+	clock := heimdalltest.NewFakeClock(time.Now())
+	l := NewAdaptiveLimiter(NewAIMD(4, 1, 10, 0.5, 50*time.Millisecond),
+		WithAdaptiveClock(clock),
+		WithOnLimitChange(func(limit int) { limits = append(limits, limit) }),
+	)
+
+	done, err := l.Acquire()
+	require.NoError(t, err)
+	clock.Advance(time.Second)
+	done(false)
+
+	assert.Equal(t, []int{2}, limits)
+	assert.Equal(t, 2, l.Limit())
+}
+
+func TestAdaptiveLimiterWrapBacksOffOnServerErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	l := NewAdaptiveLimiter(NewAIMD(8, 1, 10, 0.5, 0))
+	client := httpclient.NewClient(httpclient.WithMiddleware(l.Wrap))
+
+	response, err := client.Get(server.URL, http.Header{})
+	require.NoError(t, err)
+	response.Body.Close()
+
+	assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+	assert.Equal(t, 4, l.Limit())
+	assert.Equal(t, 0, l.InFlight())
+}
+
+func TestAdaptiveLimiterWrapUsesDroppedFunc(t *testing.T) {
+	l := NewAdaptiveLimiter(NewAIMD(2, 1, 10, 0.5, 0),
+		WithDroppedFunc(func(*http.Response, error) bool { return false }),
+	)
+	doer := l.Wrap(heimdall.DoerFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("boom")
+	}))
+
+	request, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	_, err = doer.Do(request)
+
+	assert.EqualError(t, err, "boom")
+	assert.Equal(t, 3, l.Limit(), "the error isn't a drop, the limit must grow")
+}
@@ -0,0 +1,64 @@
+package limiter
+
+import (
+	"math"
+	"time"
+)
+
+type aimd struct {
+	limit        int
+	minLimit     int
+	maxLimit     int
+	backoffRatio float64
+	timeout      time.Duration
+}
+
+// NewAIMD returns an additive increase, multiplicative decrease algorithm. The
+// limit grows by one after a successful call made while at least half of it was
+// in use, and is multiplied by backoffRatio after a dropped call or one slower
+// than timeout. A timeout of 0 disables the latency check, a maxLimit of 0
+// means no maximum
+func NewAIMD(initialLimit, minLimit, maxLimit int, backoffRatio float64, timeout time.Duration) Algorithm {
+	if backoffRatio <= 0 || backoffRatio >= 1 {
+		backoffRatio = 0.9
+	}
+	if minLimit < 1 {
+		minLimit = 1
+	}
+
+	return &aimd{
+		limit:        clamp(initialLimit, minLimit, maxLimit),
+		minLimit:     minLimit,
+		maxLimit:     maxLimit,
+		backoffRatio: backoffRatio,
+		timeout:      timeout,
+	}
+}
+
+// Limit returns the current limit
+func (a *aimd) Limit() int {
+	return a.limit
+}
+
+// OnSample adjusts the limit from the outcome of a call
+func (a *aimd) OnSample(rtt time.Duration, inFlight int, dropped bool) int {
+	switch {
+	case dropped || (a.timeout > 0 && rtt > a.timeout):
+		a.limit = int(math.Floor(float64(a.limit) * a.backoffRatio))
+	case inFlight*2 >= a.limit:
+		a.limit++
+	}
+
+	a.limit = clamp(a.limit, a.minLimit, a.maxLimit)
+	return a.limit
+}
+
+func clamp(limit, minLimit, maxLimit int) int {
+	if maxLimit > 0 && limit > maxLimit {
+		limit = maxLimit
+	}
+	if limit < minLimit {
+		limit = minLimit
+	}
+	return limit
+}
@@ -0,0 +1,237 @@
+package limiter
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+)
+
+var (
+	// ErrQueueFull is returned when no slot is free and the wait queue is full
+	ErrQueueFull = errors.New("limiter: wait queue full")
+	// ErrQueueTimeout is returned when no slot freed up within the queue timeout
+	ErrQueueTimeout = errors.New("limiter: timed out waiting for a free slot")
+)
+
+// Bulkhead limits how many requests are in flight at once, in total and per
+// host, queueing at most maxQueue requests for a free slot. It is safe for
+// concurrent use
+type Bulkhead struct {
+	maxConcurrent        int
+	maxConcurrentPerHost int
+	maxQueue             int
+	queueTimeout         time.Duration
+	keyFunc              func(*http.Request) string
+
+	mu       sync.Mutex
+	inFlight int
+	perKey   map[string]int
+	queue    *list.List // of *waiter, oldest first
+}
+
+type waiter struct {
+	key     string
+	granted chan struct{}
+}
+
+// BulkheadOption represents the bulkhead options
+type BulkheadOption func(*Bulkhead)
+
+// WithMaxConcurrent sets how many requests may be in flight at once, 0 means no limit
+func WithMaxConcurrent(maxConcurrent int) BulkheadOption {
+	return func(b *Bulkhead) {
+		b.maxConcurrent = maxConcurrent
+	}
+}
+
+// WithMaxConcurrentPerHost sets how many requests may be in flight at once to
+// a single host, 0 means no limit
+func WithMaxConcurrentPerHost(maxConcurrentPerHost int) BulkheadOption {
+	return func(b *Bulkhead) {
+		b.maxConcurrentPerHost = maxConcurrentPerHost
+	}
+}
+
+// WithMaxQueue sets how many requests may wait for a free slot, 0 rejects
+// requests as soon as no slot is free
+func WithMaxQueue(maxQueue int) BulkheadOption {
+	return func(b *Bulkhead) {
+		b.maxQueue = maxQueue
+	}
+}
+
+// WithQueueTimeout sets how long a request waits for a free slot, 0 waits
+// until the request context is done
+func WithQueueTimeout(queueTimeout time.Duration) BulkheadOption {
+	return func(b *Bulkhead) {
+		b.queueTimeout = queueTimeout
+	}
+}
+
+// WithHostKeyFunc sets how requests are mapped to the key the per host limit
+// applies to, by default the host of the URL
+func WithHostKeyFunc(keyFunc func(*http.Request) string) BulkheadOption {
+	return func(b *Bulkhead) {
+		b.keyFunc = keyFunc
+	}
+}
+
+// NewBulkhead returns a new bulkhead
+func NewBulkhead(opts ...BulkheadOption) *Bulkhead {
+	b := &Bulkhead{
+		keyFunc: func(request *http.Request) string { return request.URL.Host },
+		perKey:  map[string]int{},
+		queue:   list.New(),
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+// InFlight returns the number of requests holding a slot
+func (b *Bulkhead) InFlight() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.inFlight
+}
+
+// Acquire waits for a slot for key. On success the caller must call release
+// once the request is done
+func (b *Bulkhead) Acquire(ctx context.Context, key string) (release func(), err error) {
+	b.mu.Lock()
+	if b.canRun(key) {
+		b.take(key)
+		b.mu.Unlock()
+		return b.releaseFunc(key), nil
+	}
+
+	if b.queue.Len() >= b.maxQueue {
+		b.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+
+	w := &waiter{key: key, granted: make(chan struct{})}
+	element := b.queue.PushBack(w)
+	b.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if b.queueTimeout > 0 {
+		timer := time.NewTimer(b.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-w.granted:
+		return b.releaseFunc(key), nil
+	case <-timeout:
+		err = ErrQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	select {
+	case <-w.granted:
+		// the slot was granted while giving up, keep it
+		return b.releaseFunc(key), nil
+	default:
+		b.queue.Remove(element)
+		return nil, err
+	}
+}
+
+// Wrap returns a Doer holding a slot from sending the request until the
+// response body is closed, so it can be used as a heimdall.Middleware
+func (b *Bulkhead) Wrap(next heimdall.Doer) heimdall.Doer {
+	return heimdall.DoerFunc(func(request *http.Request) (*http.Response, error) {
+		release, err := b.Acquire(request.Context(), b.keyFunc(request))
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := next.Do(request)
+		return releaseOnClose(response, err, release)
+	})
+}
+
+func (b *Bulkhead) canRun(key string) bool {
+	if b.maxConcurrent > 0 && b.inFlight >= b.maxConcurrent {
+		return false
+	}
+	return b.maxConcurrentPerHost <= 0 || b.perKey[key] < b.maxConcurrentPerHost
+}
+
+func (b *Bulkhead) take(key string) {
+	b.inFlight++
+	b.perKey[key]++
+}
+
+func (b *Bulkhead) releaseFunc(key string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.release(key)
+		})
+	}
+}
+
+// release frees the slot of key and hands free slots to waiters in arrival
+// order, skipping those whose host is still at its limit
+func (b *Bulkhead) release(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.inFlight--
+	b.perKey[key]--
+	if b.perKey[key] <= 0 {
+		delete(b.perKey, key)
+	}
+
+	for element := b.queue.Front(); element != nil; {
+		next := element.Next()
+		w := element.Value.(*waiter)
+		if b.maxConcurrent > 0 && b.inFlight >= b.maxConcurrent {
+			return
+		}
+		if b.canRun(w.key) {
+			b.take(w.key)
+			b.queue.Remove(element)
+			close(w.granted)
+		}
+		element = next
+	}
+}
+
+// releaseOnClose calls release once the response body is closed, or right
+// away when there is no body to wait for
+func releaseOnClose(response *http.Response, err error, release func()) (*http.Response, error) {
+	if err != nil || response == nil || response.Body == nil {
+		release()
+		return response, err
+	}
+
+	response.Body = &releasingBody{ReadCloser: response.Body, release: release}
+	return response, err
+}
+
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (rb *releasingBody) Close() error {
+	err := rb.ReadCloser.Close()
+	rb.release()
+	return err
+}
@@ -0,0 +1,147 @@
+package limiter
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+	"github.com/go-light/httpclient/v3/heimdall/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkheadRejectsWhenQueueIsFull(t *testing.T) {
+	b := NewBulkhead(WithMaxConcurrent(1))
+
+	release, err := b.Acquire(context.Background(), "a")
+	require.NoError(t, err)
+
+	_, err = b.Acquire(context.Background(), "a")
+	assert.Equal(t, ErrQueueFull, err)
+
+	release()
+	release()
+	assert.Equal(t, 0, b.InFlight(), "release must be idempotent")
+}
+
+func TestBulkheadQueueTimeout(t *testing.T) {
+	b := NewBulkhead(WithMaxConcurrent(1), WithMaxQueue(1), WithQueueTimeout(5*time.Millisecond))
+
+	_, err := b.Acquire(context.Background(), "a")
+	require.NoError(t, err)
+
+	_, err = b.Acquire(context.Background(), "a")
+	assert.Equal(t, ErrQueueTimeout, err)
+}
+
+func TestBulkheadQueueHonoursContext(t *testing.T) {
+	b := NewBulkhead(WithMaxConcurrent(1), WithMaxQueue(1))
+
+	_, err := b.Acquire(context.Background(), "a")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = b.Acquire(ctx, "a")
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestBulkheadHandsSlotsToWaiters(t *testing.T) {
+	b := NewBulkhead(WithMaxConcurrent(1), WithMaxQueue(1))
+
+	release, err := b.Acquire(context.Background(), "a")
+	require.NoError(t, err)
+
+	acquired := make(chan error)
+	go func() {
+		_, err := b.Acquire(context.Background(), "a")
+		acquired <- err
+	}()
+
+	time.Sleep(5 * time.Millisecond)
+	release()
+	require.NoError(t, <-acquired)
+	assert.Equal(t, 1, b.InFlight())
+}
+
+func TestBulkheadPerHostLimit(t *testing.T) {
+	b := NewBulkhead(WithMaxConcurrentPerHost(1))
+
+	_, err := b.Acquire(context.Background(), "a")
+	require.NoError(t, err)
+
+	_, err = b.Acquire(context.Background(), "a")
+	assert.Equal(t, ErrQueueFull, err)
+
+	_, err = b.Acquire(context.Background(), "b")
+	assert.NoError(t, err, "another host must not be limited")
+}
+
+func TestBulkheadWithHTTPClient(t *testing.T) {
+	b := NewBulkhead(WithMaxConcurrent(2), WithMaxQueue(10))
+	client := httpclient.NewClient(
+		httpclient.WithHTTPTimeout(time.Second),
+		httpclient.WithMiddleware(b.Wrap),
+	)
+
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		w.Write([]byte(`ok`))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(dummyHandler))
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response, err := client.Get(server.URL, http.Header{})
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer response.Body.Close()
+			body, err := ioutil.ReadAll(response.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, "ok", string(body))
+		}()
+	}
+	wg.Wait()
+
+	assert.True(t, maxInFlight <= 2)
+	assert.Equal(t, 0, b.InFlight())
+}
+
+func TestBulkheadReleasesOnError(t *testing.T) {
+	b := NewBulkhead(WithMaxConcurrent(1))
+	doer := b.Wrap(heimdall.DoerFunc(func(*http.Request) (*http.Response, error) {
+		return nil, context.DeadlineExceeded
+	}))
+
+	request, err := http.NewRequest(http.MethodGet, "http://example.com", strings.NewReader(""))
+	require.NoError(t, err)
+
+	_, err = doer.Do(request)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 0, b.InFlight())
+}
@@ -0,0 +1,78 @@
+package limiter
+
+import (
+	"math"
+	"time"
+)
+
+const gradientLongWindow = 100
+
+type gradient struct {
+	limit     float64
+	minLimit  int
+	maxLimit  int
+	smoothing float64
+	longRTT   float64 // exponential moving average of the latency, in nanoseconds
+}
+
+// NewGradient returns an algorithm comparing the latency of every call to the
+// long term average latency. While calls are as fast as usual the limit grows
+// by its square root, as they slow down it shrinks proportionally, at most
+// halving. smoothing, in (0, 1], sets how fast the limit moves towards the
+// new value. A maxLimit of 0 means no maximum
+func NewGradient(initialLimit, minLimit, maxLimit int, smoothing float64) Algorithm {
+	if smoothing <= 0 || smoothing > 1 {
+		smoothing = 0.2
+	}
+	if minLimit < 1 {
+		minLimit = 1
+	}
+
+	return &gradient{
+		limit:     float64(clamp(initialLimit, minLimit, maxLimit)),
+		minLimit:  minLimit,
+		maxLimit:  maxLimit,
+		smoothing: smoothing,
+	}
+}
+
+// Limit returns the current limit, rounded
+func (g *gradient) Limit() int {
+	return int(math.Round(g.limit))
+}
+
+// OnSample adjusts the limit from the outcome of a call
+func (g *gradient) OnSample(rtt time.Duration, inFlight int, dropped bool) int {
+	shortRTT := float64(rtt)
+	if g.longRTT == 0 {
+		g.longRTT = shortRTT
+	} else {
+		g.longRTT += (shortRTT - g.longRTT) / gradientLongWindow
+	}
+
+	// don't grow the limit while it isn't used, there is no evidence it could be higher
+	if !dropped && float64(inFlight) < g.limit/2 {
+		return g.Limit()
+	}
+
+	ratio := 1.0
+	if shortRTT > 0 {
+		ratio = math.Max(0.5, math.Min(1, g.longRTT/shortRTT))
+	}
+	if dropped {
+		ratio = 0.5
+	}
+
+	queueSize := math.Sqrt(g.limit)
+	if dropped {
+		queueSize = 0
+	}
+	newLimit := g.limit*ratio + queueSize
+	g.limit = g.limit*(1-g.smoothing) + newLimit*g.smoothing
+	// kept fractional, small steps would otherwise be truncated away
+	if g.maxLimit > 0 {
+		g.limit = math.Min(float64(g.maxLimit), g.limit)
+	}
+	g.limit = math.Max(float64(g.minLimit), g.limit)
+	return g.Limit()
+}
@@ -0,0 +1,341 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+)
+
+// ErrRateLimited is matched by errors.Is for every RateLimitError
+var ErrRateLimited = errors.New("limiter: rate limit exceeded")
+
+// RateLimitError is returned when a request is rejected by a RateLimiter
+type RateLimitError struct {
+	// Key is the key of the bucket the request was taken from
+	Key string
+	// RetryAfter is how long until a token is available
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrRateLimited, e.RetryAfter)
+}
+
+// Is makes errors.Is(err, ErrRateLimited) true
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+const (
+	headerRateLimitRemaining = "X-RateLimit-Remaining"
+	headerRateLimitReset     = "X-RateLimit-Reset"
+	headerRetryAfter         = "Retry-After"
+
+	// resets above this many seconds are unix timestamps rather than delays
+	resetEpochThreshold = 1000000000
+
+	// buckets are not pruned before there are this many of them
+	minPruneBuckets = 64
+)
+
+// RateLimiter is a token bucket rate limiter, holding one bucket for the whole
+// client or one per host. Requests over the rate either wait for a token or are
+// rejected with a RateLimitError. Buckets which refilled up to the burst are
+// dropped as new keys come in, so per-key buckets do not grow without bound.
+// It is safe for concurrent use
+type RateLimiter struct {
+	rate          float64 // tokens per second
+	burst         int
+	wait          bool
+	maxWait       time.Duration
+	followHeaders bool
+	keyFunc       func(*http.Request) string
+	clock         heimdall.Clock
+	sleeper       heimdall.Sleeper
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	pruneAt int // number of buckets from which the full ones are dropped
+}
+
+type tokenBucket struct {
+	tokens       float64
+	rate         float64
+	last         time.Time
+	blockedUntil time.Time
+}
+
+// RateLimitOption represents the rate limiter options
+type RateLimitOption func(*RateLimiter)
+
+// WithWait makes requests over the rate wait for a token, until the request
+// context is done, instead of being rejected
+func WithWait() RateLimitOption {
+	return func(l *RateLimiter) {
+		l.wait = true
+	}
+}
+
+// WithMaxWait rejects right away the requests which would have to wait longer
+// than maxWait for a token, 0 means no limit
+func WithMaxWait(maxWait time.Duration) RateLimitOption {
+	return func(l *RateLimiter) {
+		l.maxWait = maxWait
+	}
+}
+
+// WithPerHost gives every host of the URL its own bucket
+func WithPerHost() RateLimitOption {
+	return WithRateKeyFunc(func(request *http.Request) string { return request.URL.Host })
+}
+
+// WithRateKeyFunc sets how requests are mapped to buckets, by default all
+// requests share a single bucket
+func WithRateKeyFunc(keyFunc func(*http.Request) string) RateLimitOption {
+	return func(l *RateLimiter) {
+		l.keyFunc = keyFunc
+	}
+}
+
+// WithRateLimitHeaders makes Wrap follow the X-RateLimit-Remaining and
+// X-RateLimit-Reset response headers, spreading the remaining quota over the
+// time left until the reset but never going over the configured rate, and the
+// Retry-After header of 429 responses
+func WithRateLimitHeaders() RateLimitOption {
+	return func(l *RateLimiter) {
+		l.followHeaders = true
+	}
+}
+
+// WithRateClock sets the clock used to refill the buckets
+func WithRateClock(clock heimdall.Clock) RateLimitOption {
+	return func(l *RateLimiter) {
+		l.clock = clock
+	}
+}
+
+// WithRateSleeper sets how WithWait waits for a token
+func WithRateSleeper(sleeper heimdall.Sleeper) RateLimitOption {
+	return func(l *RateLimiter) {
+		l.sleeper = sleeper
+	}
+}
+
+// NewRateLimiter returns a rate limiter allowing rate requests per second on
+// average and bursts of up to burst requests
+func NewRateLimiter(rate float64, burst int, opts ...RateLimitOption) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	l := &RateLimiter{
+		rate:    rate,
+		burst:   burst,
+		keyFunc: func(*http.Request) string { return "" },
+		clock:   heimdall.NewSystemClock(),
+		sleeper: heimdall.NewSystemSleeper(),
+		buckets: map[string]*tokenBucket{},
+		pruneAt: minPruneBuckets,
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// Rate returns the current rate of the bucket of key, in requests per second
+func (l *RateLimiter) Rate(key string) float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.bucket(key, l.clock.Now()).rate
+}
+
+// Acquire takes a token from the bucket of key, waiting for one if the limiter
+// was created with WithWait
+func (l *RateLimiter) Acquire(ctx context.Context, key string) error {
+	l.mu.Lock()
+	now := l.clock.Now()
+	b := l.bucket(key, now)
+	delay := b.delay(now)
+
+	if delay > 0 && (!l.wait || (l.maxWait > 0 && delay > l.maxWait)) {
+		l.mu.Unlock()
+		return &RateLimitError{Key: key, RetryAfter: delay}
+	}
+	// the token is reserved now so that waiters are served in order
+	b.tokens--
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	if err := l.sleeper.Sleep(ctx, delay); err != nil {
+		l.mu.Lock()
+		b.tokens++
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Wrap returns a Doer taking a token for every request sent through next, so
+// it can be used as a heimdall.Middleware
+func (l *RateLimiter) Wrap(next heimdall.Doer) heimdall.Doer {
+	return heimdall.DoerFunc(func(request *http.Request) (*http.Response, error) {
+		key := l.keyFunc(request)
+		if err := l.Acquire(request.Context(), key); err != nil {
+			return nil, err
+		}
+
+		response, err := next.Do(request)
+		if err == nil && l.followHeaders {
+			l.observe(key, response)
+		}
+		return response, err
+	})
+}
+
+// bucket returns the bucket of key refilled up to now, creating it full if needed
+func (l *RateLimiter) bucket(key string, now time.Time) *tokenBucket {
+	b, ok := l.buckets[key]
+	if ok {
+		l.refill(b, now)
+		return b
+	}
+
+	if len(l.buckets) >= l.pruneAt {
+		l.prune(now)
+	}
+	b = &tokenBucket{tokens: float64(l.burst), rate: l.rate, last: now}
+	l.buckets[key] = b
+	return b
+}
+
+// refill adds the tokens earned by b since it was last refilled, up to the burst
+func (l *RateLimiter) refill(b *tokenBucket, now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > float64(l.burst) {
+			b.tokens = float64(l.burst)
+		}
+		b.last = now
+	}
+}
+
+// prune drops the buckets which are full and not blocked, as a new bucket would
+// be created in the same state. The rate learnt from the rate limit headers is
+// learnt again from the next response. The next prune happens once the number
+// of buckets doubled, which keeps it amortized constant time
+func (l *RateLimiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= float64(l.burst) && !now.Before(b.blockedUntil) {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.pruneAt = 2 * len(l.buckets)
+	if l.pruneAt < minPruneBuckets {
+		l.pruneAt = minPruneBuckets
+	}
+}
+
+// delay returns how long until the bucket holds a token
+func (b *tokenBucket) delay(now time.Time) time.Duration {
+	var delay time.Duration
+	if b.tokens < 1 {
+		if b.rate <= 0 {
+			delay = time.Duration(1<<63 - 1)
+		} else {
+			delay = time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		}
+	}
+	if blocked := b.blockedUntil.Sub(now); blocked > delay {
+		delay = blocked
+	}
+	return delay
+}
+
+// observe adjusts the bucket of key from the rate limit headers of response
+func (l *RateLimiter) observe(key string, response *http.Response) {
+	if response == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	b := l.bucket(key, now)
+
+	if response.StatusCode == http.StatusTooManyRequests {
+		if retryAfter, ok := parseRetryAfter(response.Header.Get(headerRetryAfter), now); ok {
+			b.blockedUntil = now.Add(retryAfter)
+			b.tokens = 0
+		}
+	}
+
+	remaining, err := strconv.Atoi(response.Header.Get(headerRateLimitRemaining))
+	if err != nil || remaining < 0 {
+		return
+	}
+	if float64(remaining) < b.tokens {
+		b.tokens = float64(remaining)
+	}
+
+	untilReset, ok := parseReset(response.Header.Get(headerRateLimitReset), now)
+	if !ok {
+		return
+	}
+	if remaining == 0 {
+		b.blockedUntil = now.Add(untilReset)
+		return
+	}
+
+	b.rate = l.rate
+	if untilReset > 0 {
+		if spread := float64(remaining) / untilReset.Seconds(); spread < b.rate {
+			b.rate = spread
+		}
+	}
+}
+
+// parseReset reads an X-RateLimit-Reset value, either a number of seconds or a
+// unix timestamp, and returns the time left until the reset
+func parseReset(value string, now time.Time) (time.Duration, bool) {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	if seconds > resetEpochThreshold {
+		untilReset := time.Unix(seconds, 0).Sub(now)
+		if untilReset < 0 {
+			untilReset = 0
+		}
+		return untilReset, true
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// parseRetryAfter reads a Retry-After value, either a number of seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if at.Before(now) {
+			return 0, true
+		}
+		return at.Sub(now), true
+	}
+	return 0, false
+}
@@ -0,0 +1,144 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall/heimdalltest"
+	"github.com/go-light/httpclient/v3/heimdall/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiterAllowsBurstThenRejects(t *testing.T) {
+	clock := heimdalltest.NewFakeClock(time.Now())
+	l := NewRateLimiter(10, 2, WithRateClock(clock))
+
+	require.NoError(t, l.Acquire(context.Background(), ""))
+	require.NoError(t, l.Acquire(context.Background(), ""))
+
+	err := l.Acquire(context.Background(), "")
+	require.True(t, errors.Is(err, ErrRateLimited))
+	var rateLimitErr *RateLimitError
+	require.True(t, errors.As(err, &rateLimitErr))
+	assert.Equal(t, 100*time.Millisecond, rateLimitErr.RetryAfter)
+
+	clock.Advance(100 * time.Millisecond)
+	assert.NoError(t, l.Acquire(context.Background(), ""))
+}
+
+func TestRateLimiterKeepsOneBucketPerKey(t *testing.T) {
+	clock := heimdalltest.NewFakeClock(time.Now())
+	l := NewRateLimiter(1, 1, WithRateClock(clock))
+
+	require.NoError(t, l.Acquire(context.Background(), "a"))
+	assert.NoError(t, l.Acquire(context.Background(), "b"))
+	assert.Error(t, l.Acquire(context.Background(), "a"))
+}
+
+func TestRateLimiterDropsFullBuckets(t *testing.T) {
+	clock := heimdalltest.NewFakeClock(time.Now())
+	l := NewRateLimiter(1, 1, WithRateClock(clock))
+
+	for i := 0; i < minPruneBuckets-1; i++ {
+		require.NoError(t, l.Acquire(context.Background(), strconv.Itoa(i)))
+	}
+	clock.Advance(500 * time.Millisecond)
+	require.NoError(t, l.Acquire(context.Background(), "half"))
+
+	clock.Advance(500 * time.Millisecond)
+	require.NoError(t, l.Acquire(context.Background(), "new"))
+
+	assert.Len(t, l.buckets, 2, "only the buckets still refilling must be kept")
+	assert.Error(t, l.Acquire(context.Background(), "half"))
+	assert.Equal(t, minPruneBuckets, l.pruneAt)
+}
+
+func TestRateLimiterWaitsForToken(t *testing.T) {
+	clock := heimdalltest.NewFakeClock(time.Now())
+	l := NewRateLimiter(50, 1, WithWait(), WithRateClock(clock), WithRateSleeper(clock))
+
+	require.NoError(t, l.Acquire(context.Background(), ""))
+	require.NoError(t, l.Acquire(context.Background(), ""))
+	assert.Equal(t, []time.Duration{20 * time.Millisecond}, clock.Sleeps())
+}
+
+func TestRateLimiterWaitHonoursContext(t *testing.T) {
+	clock := heimdalltest.NewFakeClock(time.Now())
+	l := NewRateLimiter(0.1, 1, WithWait(), WithRateClock(clock), WithRateSleeper(clock))
+
+	require.NoError(t, l.Acquire(context.Background(), ""))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, l.Acquire(ctx, ""))
+
+	clock.Advance(10 * time.Second)
+	require.NoError(t, l.Acquire(context.Background(), ""), "the token of the cancelled wait must be given back")
+}
+
+func TestRateLimiterRejectsWaitsOverMaxWait(t *testing.T) {
+	clock := heimdalltest.NewFakeClock(time.Now())
+	l := NewRateLimiter(0.1, 1, WithWait(), WithMaxWait(time.Millisecond), WithRateClock(clock), WithRateSleeper(clock))
+
+	require.NoError(t, l.Acquire(context.Background(), ""))
+	assert.True(t, errors.Is(l.Acquire(context.Background(), ""), ErrRateLimited))
+	assert.Empty(t, clock.Sleeps())
+}
+
+func TestRateLimiterFollowsRateLimitHeaders(t *testing.T) {
+	remaining := 5
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("X-RateLimit-Reset", "10")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	clock := heimdalltest.NewFakeClock(time.Now())
+	l := NewRateLimiter(100, 10, WithPerHost(), WithRateLimitHeaders(), WithRateClock(clock))
+	client := httpclient.NewClient(httpclient.WithMiddleware(l.Wrap))
+
+	response, err := client.Get(server.URL, http.Header{})
+	require.NoError(t, err)
+	response.Body.Close()
+
+	host := response.Request.URL.Host
+	assert.Equal(t, 0.5, l.Rate(host), "the remaining quota must be spread until the reset")
+
+	remaining = 0
+	response, err = client.Get(server.URL, http.Header{})
+	require.NoError(t, err)
+	response.Body.Close()
+
+	err = l.Acquire(context.Background(), host)
+	var rateLimitErr *RateLimitError
+	require.True(t, errors.As(err, &rateLimitErr))
+	assert.Equal(t, 10*time.Second, rateLimitErr.RetryAfter)
+}
+
+func TestRateLimiterFollowsRetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	clock := heimdalltest.NewFakeClock(time.Now())
+	l := NewRateLimiter(100, 10, WithRateLimitHeaders(), WithRateClock(clock))
+	client := httpclient.NewClient(httpclient.WithMiddleware(l.Wrap))
+
+	response, err := client.Get(server.URL, http.Header{})
+	require.NoError(t, err)
+	response.Body.Close()
+
+	err = l.Acquire(context.Background(), "")
+	var rateLimitErr *RateLimitError
+	require.True(t, errors.As(err, &rateLimitErr))
+	assert.Equal(t, 3*time.Second, rateLimitErr.RetryAfter)
+}
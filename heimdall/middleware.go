@@ -0,0 +1,23 @@
+package heimdall
+
+import "net/http"
+
+// DoerFunc is an adapter to allow the use of ordinary functions as a Doer
+type DoerFunc func(*http.Request) (*http.Response, error)
+
+// Do calls f(request)
+func (f DoerFunc) Do(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+// Middleware wraps a Doer to add behaviour around every attempt it sends,
+// such as circuit breaking or concurrency limiting
+type Middleware func(Doer) Doer
+
+// Chain wraps doer with the given middlewares, the first one being the outermost
+func Chain(doer Doer, middlewares ...Middleware) Doer {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		doer = middlewares[i](doer)
+	}
+	return doer
+}
@@ -0,0 +1,32 @@
+package heimdall
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChainWrapsOutermostFirst(t *testing.T) {
+	var calls []string
+
+	named := func(name string) Middleware {
+		return func(next Doer) Doer {
+			return DoerFunc(func(request *http.Request) (*http.Response, error) {
+				calls = append(calls, name)
+				return next.Do(request)
+			})
+		}
+	}
+
+	doer := Chain(DoerFunc(func(*http.Request) (*http.Response, error) {
+		calls = append(calls, "doer")
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	}), named("first"), named("second"))
+
+	response, err := doer.Do(&http.Request{})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, []string{"first", "second", "doer"}, calls)
+}
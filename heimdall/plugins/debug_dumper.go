@@ -0,0 +1,303 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/go-light/httpclient/v3/heimdall"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultDumpMaxBodySize = 4096
+
+// DebugDumper is a Heimdall plugin which writes, for every attempt, an equivalent
+// curl command and the raw HTTP/1.1 transcript of the request and response.
+// It starts disabled and can be switched on and off at runtime
+type DebugDumper struct {
+	out             io.Writer
+	maxBodySize     int64
+	redactedHeaders map[string]bool
+
+	enabled int32
+	mu      sync.Mutex // serializes writes so concurrent dumps don't interleave
+}
+
+var _ heimdall.Plugin = (*DebugDumper)(nil)
+
+// DebugDumperOption represents the debug dumper options
+type DebugDumperOption func(*DebugDumper)
+
+// WithDumpMaxBodySize sets how many bytes of a request or response body are dumped
+func WithDumpMaxBodySize(maxBodySize int64) DebugDumperOption {
+	return func(d *DebugDumper) {
+		d.maxBodySize = maxBodySize
+	}
+}
+
+// WithRedactedHeaders adds headers whose values are replaced by [REDACTED],
+// on top of heimdall.RedactedHeaders
+func WithRedactedHeaders(headers ...string) DebugDumperOption {
+	return func(d *DebugDumper) {
+		for _, header := range headers {
+			d.redactedHeaders[http.CanonicalHeaderKey(header)] = true
+		}
+	}
+}
+
+// NewDebugDumper returns a new, disabled, debug dumper plugin writing to out.
+// If given as nil, `out` takes the default value of `os.Stderr`
+func NewDebugDumper(out io.Writer, opts ...DebugDumperOption) *DebugDumper {
+	if out == nil {
+		out = os.Stderr
+	}
+
+	d := &DebugDumper{
+		out:             out,
+		maxBodySize:     defaultDumpMaxBodySize,
+		redactedHeaders: map[string]bool{},
+	}
+	for _, header := range heimdall.RedactedHeaders() {
+		d.redactedHeaders[header] = true
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// Enable starts dumping requests and responses
+func (d *DebugDumper) Enable() {
+	atomic.StoreInt32(&d.enabled, 1)
+}
+
+// Disable stops dumping requests and responses
+func (d *DebugDumper) Disable() {
+	atomic.StoreInt32(&d.enabled, 0)
+}
+
+// Enabled reports whether the dumper is currently enabled
+func (d *DebugDumper) Enabled() bool {
+	return atomic.LoadInt32(&d.enabled) == 1
+}
+
+// OnRequestStart dumps the curl command and the outgoing request
+func (d *DebugDumper) OnRequestStart(req *http.Request) {
+	if !d.Enabled() {
+		return
+	}
+
+	body, size, err := d.requestBody(req)
+	if err != nil {
+		d.write(fmt.Sprintf("=== request %s ===\nfailed to read request body: %v\n", attemptLabel(req), err))
+		return
+	}
+
+	// DumpRequestOut round trips the request over a fake connection, which must
+	// not fire the httptrace hooks other plugins attached to the context
+	redactedReq := req.Clone(context.Background())
+	redactedReq.URL = heimdall.RedactURL(req.URL)
+	redactedReq.Header = d.redact(req.Header)
+
+	head, err := httputil.DumpRequestOut(redactedReq, false)
+	if err != nil {
+		d.write(fmt.Sprintf("=== request %s ===\nfailed to dump request: %v\n", attemptLabel(req), err))
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "=== request %s ===\n", attemptLabel(req))
+	b.WriteString(d.curl(redactedReq, body, size))
+	b.WriteString("\n\n")
+	b.Write(head)
+	b.WriteString(d.formatBody(body, size))
+	d.write(b.String())
+}
+
+// OnRequestEnd dumps the response, leaving its body readable for the caller
+func (d *DebugDumper) OnRequestEnd(req *http.Request, res *http.Response) {
+	if !d.Enabled() {
+		return
+	}
+
+	body, err := peekResponseBody(res, d.maxBodySize+1)
+	if err != nil {
+		d.write(fmt.Sprintf("=== response %s ===\nfailed to read response body: %v\n", attemptLabel(req), err))
+		return
+	}
+
+	redactedRes := *res
+	redactedRes.Header = d.redact(res.Header)
+	redactedRes.Body = nil
+	head, err := httputil.DumpResponse(&redactedRes, false)
+	if err != nil {
+		d.write(fmt.Sprintf("=== response %s ===\nfailed to dump response: %v\n", attemptLabel(req), err))
+		return
+	}
+
+	size := res.ContentLength
+	if size < 0 || int64(len(body)) <= d.maxBodySize {
+		size = int64(len(body))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "=== response %s ===\n", attemptLabel(req))
+	b.Write(head)
+	b.WriteString(d.formatBody(body, size))
+	d.write(b.String())
+}
+
+// OnError dumps the error the attempt failed with
+func (d *DebugDumper) OnError(req *http.Request, err error) {
+	if !d.Enabled() {
+		return
+	}
+
+	d.write(fmt.Sprintf("=== response %s ===\nERROR: %v\n", attemptLabel(req), err))
+}
+
+// requestBody returns at most maxBodySize+1 bytes of the request body and its full size
+func (d *DebugDumper) requestBody(req *http.Request) ([]byte, int64, error) {
+	body, err := peekRequestBody(req, d.maxBodySize+1)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	size := req.ContentLength
+	if size <= 0 {
+		size = int64(len(body))
+	}
+	return body, size, nil
+}
+
+func (d *DebugDumper) redact(header http.Header) http.Header {
+	return redactHeader(header, d.redactedHeaders)
+}
+
+// curl returns a shell command sending the same request with curl
+func (d *DebugDumper) curl(req *http.Request, body []byte, size int64) string {
+	parts := []string{"curl", "-X", shellQuote(req.Method), shellQuote(req.URL.String())}
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range req.Header[name] {
+			parts = append(parts, "-H", shellQuote(name+": "+value))
+		}
+	}
+
+	if len(body) > 0 {
+		shown, truncated := d.truncate(body, size)
+		parts = append(parts, "--data-binary", shellQuote(string(shown)))
+		if truncated != "" {
+			parts = append(parts, "#", truncated)
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func (d *DebugDumper) formatBody(body []byte, size int64) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	shown, truncated := d.truncate(body, size)
+	if truncated != "" {
+		return string(shown) + "\n" + truncated + "\n"
+	}
+	return string(shown) + "\n"
+}
+
+// truncate cuts body to maxBodySize and describes what was left out
+func (d *DebugDumper) truncate(body []byte, size int64) ([]byte, string) {
+	if int64(len(body)) <= d.maxBodySize {
+		return body, ""
+	}
+
+	if size <= d.maxBodySize {
+		return body[:d.maxBodySize], fmt.Sprintf("[body truncated to %d bytes]", d.maxBodySize)
+	}
+	return body[:d.maxBodySize], fmt.Sprintf("[body truncated, %d of %d bytes shown]", d.maxBodySize, size)
+}
+
+func (d *DebugDumper) write(dump string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fmt.Fprintf(d.out, "%s %s\n", time.Now().Format("02/Jan/2006 03:04:05"), dump)
+}
+
+// peekRequestBody returns at most limit bytes of the request body, read through
+// GetBody so the body being sent is left untouched
+func peekRequestBody(req *http.Request, limit int64) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody == nil {
+		return nil, nil
+	}
+
+	rc, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return ioutil.ReadAll(io.LimitReader(rc, limit))
+}
+
+// peekResponseBody reads at most limit bytes of the response body and puts
+// them back in front of the remaining body
+func peekResponseBody(res *http.Response, limit int64) ([]byte, error) {
+	if res.Body == nil || res.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, limit))
+	if err != nil {
+		return nil, err
+	}
+
+	res.Body = &peekedBody{
+		Reader: io.MultiReader(bytes.NewReader(body), res.Body),
+		Closer: res.Body,
+	}
+	return body, nil
+}
+
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
+
+func redactHeader(header http.Header, redactedHeaders map[string]bool) http.Header {
+	redactedHeader := header.Clone()
+	for name := range redactedHeader {
+		if redactedHeaders[http.CanonicalHeaderKey(name)] {
+			redactedHeader[name] = []string{heimdall.Redacted}
+		}
+	}
+	return redactedHeader
+}
+
+func attemptLabel(req *http.Request) string {
+	label := req.Method + " " + heimdall.RedactURL(req.URL).String()
+	if attempt, ok := heimdall.AttemptFromContext(req.Context()); ok {
+		label = fmt.Sprintf("%s (attempt %d)", label, attempt.Number)
+	}
+	return label
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,152 @@
+package plugins
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugDumperDumpsRequestAndResponse(t *testing.T) {
+	out := &bytes.Buffer{}
+	dumper := NewDebugDumper(out, WithDumpMaxBodySize(8), WithRedactedHeaders("x-secret"))
+	dumper.Enable()
+
+	client := httpclient.NewClient(httpclient.WithHTTPTimeout(100 * time.Millisecond))
+	client.AddPlugin(dumper)
+
+	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, `{"name":"heimdall"}`, string(body))
+		assert.Equal(t, "s3cr3t", r.Header.Get("X-Secret"))
+
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{ "response": "ok" }`))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(dummyHandler))
+	defer server.Close()
+
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+	headers.Set("Authorization", "Bearer token")
+	headers.Set("X-Secret", "s3cr3t")
+
+	response, err := client.Post(server.URL+"/it's", strings.NewReader(`{"name":"heimdall"}`), headers)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{ "response": "ok" }`, string(body), "the response body must still be readable")
+
+	dump := out.String()
+	assert.Contains(t, dump, "=== request POST "+server.URL+"/it's (attempt 0) ===")
+	assert.Contains(t, dump, "curl -X 'POST' '"+server.URL+`/it'\''s'`)
+	assert.Contains(t, dump, `-H 'Authorization: [REDACTED]'`)
+	assert.Contains(t, dump, `-H 'X-Secret: [REDACTED]'`)
+	assert.Contains(t, dump, `--data-binary '{"name":' # [body truncated, 8 of 19 bytes shown]`)
+	assert.Contains(t, dump, "POST /it's HTTP/1.1\r\n")
+	assert.Contains(t, dump, "=== response POST "+server.URL+"/it's (attempt 0) ===")
+	assert.Contains(t, dump, "HTTP/1.1 200 OK\r\n")
+	assert.Contains(t, dump, "Set-Cookie: [REDACTED]\r\n")
+	assert.Contains(t, dump, "{ \"respo\n[body truncated, 8 of 20 bytes shown]")
+	assert.NotContains(t, dump, "s3cr3t")
+	assert.NotContains(t, dump, "Bearer token")
+}
+
+func TestDebugDumperCanBeToggled(t *testing.T) {
+	out := &bytes.Buffer{}
+	dumper := NewDebugDumper(out)
+
+	client := httpclient.NewClient(httpclient.WithHTTPTimeout(100 * time.Millisecond))
+	client.AddPlugin(dumper)
+
+	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(dummyHandler))
+	defer server.Close()
+
+	_, err := client.Get(server.URL, http.Header{})
+	require.NoError(t, err)
+	assert.False(t, dumper.Enabled())
+	assert.Empty(t, out.String())
+
+	dumper.Enable()
+	_, err = client.Get(server.URL, http.Header{})
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "curl -X 'GET' '"+server.URL+"'")
+
+	out.Reset()
+	dumper.Disable()
+	_, err = client.Get(server.URL, http.Header{})
+	require.NoError(t, err)
+	assert.Empty(t, out.String())
+}
+
+func TestDebugDumperDumpsErrors(t *testing.T) {
+	out := &bytes.Buffer{}
+	dumper := NewDebugDumper(out)
+	dumper.Enable()
+
+	client := httpclient.NewClient(httpclient.WithHTTPTimeout(10 * time.Millisecond))
+	client.AddPlugin(dumper)
+
+	_, err := client.Get("url_doesnt_exist", http.Header{})
+	require.Error(t, err)
+
+	assert.Contains(t, out.String(), "ERROR: ")
+}
+
+func TestDebugDumperRedactsURLCredentials(t *testing.T) {
+	out := &bytes.Buffer{}
+	dumper := NewDebugDumper(out)
+	dumper.Enable()
+
+	client := httpclient.NewClient(httpclient.WithHTTPTimeout(100 * time.Millisecond))
+	client.AddPlugin(dumper)
+
+	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "abc", r.URL.Query().Get("token"))
+		w.WriteHeader(http.StatusOK)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(dummyHandler))
+	defer server.Close()
+
+	_, err := client.Get(strings.Replace(server.URL, "http://", "http://user:pass@", 1)+"/?token=abc", http.Header{})
+	require.NoError(t, err)
+
+	dump := out.String()
+	assert.Contains(t, dump, "curl -X 'GET' '"+strings.Replace(server.URL, "http://", "http://REDACTED@", 1)+"/?token=REDACTED'")
+	assert.Contains(t, dump, "GET /?token=REDACTED HTTP/1.1\r\n")
+	assert.NotContains(t, dump, "pass")
+	assert.NotContains(t, dump, "abc")
+}
+
+func TestDebugDumperDoesNotFireOtherPluginsTraces(t *testing.T) {
+	dumper := NewDebugDumper(&bytes.Buffer{})
+	dumper.Enable()
+	recorder := NewHARRecorder()
+
+	client := httpclient.NewClient(httpclient.WithHTTPTimeout(100 * time.Millisecond))
+	client.AddPlugin(recorder)
+	client.AddPlugin(dumper)
+
+	_, err := client.Get("http://127.0.0.1:1/refused", http.Header{})
+	require.Error(t, err)
+
+	entries := recorder.HAR().Log.Entries
+	require.Len(t, entries, 1)
+	assert.Equal(t, float64(0), entries[0].Timings.Receive, "no response byte was received")
+	assert.Empty(t, entries[0].ServerIPAddress)
+}
@@ -0,0 +1,446 @@
+package plugins
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/go-light/httpclient/v3/heimdall"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	harTimingKey ctxKey = "har_timing"
+
+	defaultHARMaxBodySize = 64 * 1024
+	defaultHARMaxEntries  = 1000
+)
+
+// HAR is the root of an HTTP Archive 1.2 document, see http://www.softwareishard.com/blog/har-12-spec/
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog holds the recorded entries of a HAR document
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator describes the application which created the HAR document
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is a single recorded attempt. Attempts which failed without a
+// response have a zero status and the error in the custom _error field
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Attempt         int         `json:"_attempt"`
+	Error           string      `json:"_error,omitempty"`
+}
+
+// HARRequest describes the recorded request
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARResponse describes the recorded response
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARNameValue is a header, cookie or query string parameter
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData is the recorded request body, Text is cut to the recorder's body size limit
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HARContent is the recorded response body, Text is cut to the recorder's body size limit
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARTimings holds the timings of an entry in milliseconds, -1 meaning not applicable
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// HARRecorder is a Heimdall plugin recording every attempt of a client as an
+// entry of an HTTP Archive, which can be loaded into browser devtools or replay tools
+type HARRecorder struct {
+	maxBodySize     int64
+	maxEntries      int
+	redactedHeaders map[string]bool
+
+	mu      sync.Mutex
+	entries []*HAREntry
+}
+
+var _ heimdall.ContextPlugin = (*HARRecorder)(nil)
+
+// HARRecorderOption represents the HAR recorder options
+type HARRecorderOption func(*HARRecorder)
+
+// WithHARMaxBodySize sets how many bytes of a request or response body are recorded
+func WithHARMaxBodySize(maxBodySize int64) HARRecorderOption {
+	return func(r *HARRecorder) {
+		r.maxBodySize = maxBodySize
+	}
+}
+
+// WithHARMaxEntries sets how many entries are kept before the oldest are dropped
+func WithHARMaxEntries(maxEntries int) HARRecorderOption {
+	return func(r *HARRecorder) {
+		r.maxEntries = maxEntries
+	}
+}
+
+// WithHARRedactedHeaders adds headers whose values are replaced by [REDACTED],
+// on top of heimdall.RedactedHeaders
+func WithHARRedactedHeaders(headers ...string) HARRecorderOption {
+	return func(r *HARRecorder) {
+		for _, header := range headers {
+			r.redactedHeaders[http.CanonicalHeaderKey(header)] = true
+		}
+	}
+}
+
+// NewHARRecorder returns a new instance of a HAR recorder plugin
+func NewHARRecorder(opts ...HARRecorderOption) *HARRecorder {
+	r := &HARRecorder{
+		maxBodySize:     defaultHARMaxBodySize,
+		maxEntries:      defaultHARMaxEntries,
+		redactedHeaders: map[string]bool{},
+	}
+	for _, header := range heimdall.RedactedHeaders() {
+		r.redactedHeaders[header] = true
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// AttemptContext attaches an httptrace.ClientTrace recording the connection
+// timings of the attempt
+func (r *HARRecorder) AttemptContext(ctx context.Context) context.Context {
+	attempt, _ := heimdall.AttemptFromContext(ctx)
+	recorder := &heimdall.ConnRecorder{Clock: attempt.Clock}
+	ctx = context.WithValue(ctx, harTimingKey, recorder)
+	return httptrace.WithClientTrace(ctx, recorder.ClientTrace())
+}
+
+func (r *HARRecorder) OnRequestStart(req *http.Request) {}
+
+// OnRequestEnd records the attempt, leaving the response body readable for the
+// caller. The entry is recorded once the headers are received, its receive
+// timing and time are completed when the caller reads the body to its end or closes it
+func (r *HARRecorder) OnRequestEnd(req *http.Request, res *http.Response) {
+	entry := r.newEntry(req)
+	attempt, _ := heimdall.AttemptFromContext(req.Context())
+	headersReceived := attempt.Now()
+
+	body, err := peekResponseBody(res, r.maxBodySize)
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	size := res.ContentLength
+	if size < 0 {
+		size = int64(len(body))
+	}
+
+	mimeType := res.Header.Get("Content-Type")
+	content := HARContent{Size: size, MimeType: mimeType}
+	content.Text, content.Encoding = harText(body)
+
+	entry.Response = HARResponse{
+		Status:      res.StatusCode,
+		StatusText:  http.StatusText(res.StatusCode),
+		HTTPVersion: res.Proto,
+		Cookies:     harCookies(res.Cookies(), r.redactedHeaders["Set-Cookie"]),
+		Headers:     harHeaders(redactHeader(res.Header, r.redactedHeaders)),
+		Content:     content,
+		RedirectURL: res.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    size,
+	}
+
+	r.add(entry)
+	res.Body = &harBody{ReadCloser: res.Body, done: func() {
+		receive := attempt.Now().Sub(headersReceived)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		entry.Timings.Receive = milliseconds(receive)
+		entry.Time += entry.Timings.Receive
+	}}
+}
+
+// OnError records the failed attempt
+func (r *HARRecorder) OnError(req *http.Request, err error) {
+	entry := r.newEntry(req)
+	entry.Error = err.Error()
+	entry.Response = HARResponse{
+		Cookies:     []HARNameValue{},
+		Headers:     []HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+
+	r.add(entry)
+}
+
+// HAR returns a snapshot of the recorded entries
+func (r *HARRecorder) HAR() *HAR {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return newHAR(copyEntries(r.entries))
+}
+
+// Flush writes the recorded entries to w as a HAR document and clears them
+func (r *HARRecorder) Flush(w io.Writer) error {
+	r.mu.Lock()
+	har := newHAR(copyEntries(r.entries))
+	r.entries = nil
+	r.mu.Unlock()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(har)
+}
+
+func newHAR(entries []HAREntry) *HAR {
+	return &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "heimdall", Version: "1.0"},
+		Entries: entries,
+	}}
+}
+
+func (r *HARRecorder) add(entry *HAREntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, entry)
+	if r.maxEntries > 0 && len(r.entries) > r.maxEntries {
+		r.entries = append([]*HAREntry(nil), r.entries[len(r.entries)-r.maxEntries:]...)
+	}
+}
+
+// copyEntries returns a snapshot of entries, to be called with the lock held
+func copyEntries(entries []*HAREntry) []HAREntry {
+	copied := make([]HAREntry, 0, len(entries))
+	for _, entry := range entries {
+		copied = append(copied, *entry)
+	}
+	return copied
+}
+
+// newEntry fills in the request and timings, up to the response headers, of
+// the attempt req belongs to
+func (r *HARRecorder) newEntry(req *http.Request) *HAREntry {
+	attempt, ok := heimdall.AttemptFromContext(req.Context())
+	now := attempt.Now()
+	start := now
+	entry := &HAREntry{}
+	if ok {
+		start = attempt.Start
+		entry.Attempt = attempt.Number
+	}
+	entry.StartedDateTime = start.Format(time.RFC3339Nano)
+	entry.Time = milliseconds(now.Sub(start))
+
+	entry.Timings = HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Wait: entry.Time}
+	if recorder, ok := req.Context().Value(harTimingKey).(*heimdall.ConnRecorder); ok {
+		events := recorder.Events()
+		dns, connect, tlsHandshake, ttfb := durations(req.Context(), events)
+		entry.Timings = harTimings(dns, connect, tlsHandshake, ttfb, now.Sub(start))
+		entry.ServerIPAddress = remoteIP(events)
+	}
+
+	redactedURL := heimdall.RedactURL(req.URL)
+	entry.Request = HARRequest{
+		Method:      req.Method,
+		URL:         redactedURL.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     harCookies(req.Cookies(), r.redactedHeaders["Cookie"]),
+		Headers:     harHeaders(redactHeader(req.Header, r.redactedHeaders)),
+		QueryString: harQueryString(redactedURL),
+		HeadersSize: -1,
+		BodySize:    req.ContentLength,
+	}
+
+	body, err := peekRequestBody(req, r.maxBodySize)
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if len(body) > 0 {
+		text, _ := harText(body)
+		entry.Request.PostData = &HARPostData{MimeType: req.Header.Get("Content-Type"), Text: text}
+	}
+
+	return entry
+}
+
+// harTimings splits the attempt duration, up to the response headers, into the
+// HAR phases. Connect includes the TLS handshake, as required by the spec, and
+// wait is the time to first byte left once DNS and connect are accounted for
+func harTimings(dns, connect, tlsHandshake, ttfb, total time.Duration) HARTimings {
+	timings := HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1}
+	if dns > 0 {
+		timings.DNS = milliseconds(dns)
+	}
+	if connect > 0 || tlsHandshake > 0 {
+		timings.Connect = milliseconds(connect + tlsHandshake)
+	}
+	if tlsHandshake > 0 {
+		timings.SSL = milliseconds(tlsHandshake)
+	}
+
+	wait := ttfb - dns - connect - tlsHandshake
+	if ttfb == 0 {
+		wait = total - dns - connect - tlsHandshake
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	timings.Wait = milliseconds(wait)
+	return timings
+}
+
+// remoteIP returns the IP address of the connection the attempt was sent on
+func remoteIP(events heimdall.ConnEvents) string {
+	host, _, err := net.SplitHostPort(events.RemoteAddr())
+	if err != nil {
+		return ""
+	}
+	return host
+}
+
+// harBody calls done once the response body has been read to its end or closed
+type harBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (b *harBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.done)
+	}
+	return n, err
+}
+
+func (b *harBody) Close() error {
+	b.once.Do(b.done)
+	return b.ReadCloser.Close()
+}
+
+func harHeaders(header http.Header) []HARNameValue {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	headers := []HARNameValue{}
+	for _, name := range names {
+		for _, value := range header[name] {
+			headers = append(headers, HARNameValue{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+func harCookies(cookies []*http.Cookie, redact bool) []HARNameValue {
+	values := []HARNameValue{}
+	for _, cookie := range cookies {
+		value := cookie.Value
+		if redact {
+			value = heimdall.Redacted
+		}
+		values = append(values, HARNameValue{Name: cookie.Name, Value: value})
+	}
+	return values
+}
+
+func harQueryString(u *url.URL) []HARNameValue {
+	query := u.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := []HARNameValue{}
+	for _, name := range names {
+		for _, value := range query[name] {
+			values = append(values, HARNameValue{Name: name, Value: value})
+		}
+	}
+	return values
+}
+
+// harText returns body as HAR content text, base64 encoded when it isn't valid UTF-8 text
+func harText(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,123 @@
+package plugins
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+	"github.com/go-light/httpclient/v3/heimdall/heimdalltest"
+	"github.com/go-light/httpclient/v3/heimdall/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHARRecorderRecordsAttempts(t *testing.T) {
+	recorder := NewHARRecorder(WithHARMaxBodySize(10))
+	client := httpclient.NewClient(
+		httpclient.WithHTTPTimeout(100*time.Millisecond),
+		httpclient.WithRetryCount(1),
+		httpclient.WithRetrier(heimdall.NewRetrier(heimdall.NewConstantBackoff(1*time.Millisecond, 0))),
+	)
+	client.AddPlugin(recorder)
+
+	count := 0
+	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
+		count++
+		w.Header().Set("Content-Type", "application/json")
+		if count == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{ "response": "ok" }`))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(dummyHandler))
+	defer server.Close()
+
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+	headers.Set("Authorization", "Bearer token")
+
+	response, err := client.Post(server.URL+"/items?page=2&token=s3cr3t", strings.NewReader(`{"name":"heimdall"}`), headers)
+	require.NoError(t, err)
+	assert.Equal(t, `{ "response": "ok" }`, heimdalltest.ResponseBody(t, response), "the response body must still be readable")
+
+	har := recorder.HAR()
+	assert.Equal(t, "1.2", har.Log.Version)
+	require.Len(t, har.Log.Entries, 2)
+
+	first := har.Log.Entries[0]
+	assert.Equal(t, 0, first.Attempt)
+	assert.Equal(t, http.StatusServiceUnavailable, first.Response.Status)
+	assert.Equal(t, "127.0.0.1", first.ServerIPAddress)
+
+	second := har.Log.Entries[1]
+	assert.Equal(t, 1, second.Attempt)
+	assert.Equal(t, http.MethodPost, second.Request.Method)
+	assert.Equal(t, server.URL+"/items?page=2&token=REDACTED", second.Request.URL)
+	assert.Equal(t, "HTTP/1.1", second.Request.HTTPVersion)
+	assert.Equal(t, []HARNameValue{{Name: "page", Value: "2"}, {Name: "token", Value: "REDACTED"}}, second.Request.QueryString)
+	assert.Contains(t, second.Request.Headers, HARNameValue{Name: "Authorization", Value: "[REDACTED]"})
+	require.NotNil(t, second.Request.PostData)
+	assert.Equal(t, `{"name":"h`, second.Request.PostData.Text)
+	assert.Equal(t, http.StatusOK, second.Response.Status)
+	assert.Equal(t, "application/json", second.Response.Content.MimeType)
+	assert.Equal(t, int64(20), second.Response.Content.Size)
+	assert.Equal(t, `{ "respons`, second.Response.Content.Text)
+	assert.True(t, second.Time >= 0)
+	assert.True(t, second.Timings.Wait >= 0)
+}
+
+func TestHARRecorderRecordsReceiveOnceTheBodyIsRead(t *testing.T) {
+	recorder := NewHARRecorder()
+	client := httpclient.NewClient(httpclient.WithHTTPTimeout(time.Second))
+	client.AddPlugin(recorder)
+
+	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("slow body"))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(dummyHandler))
+	defer server.Close()
+
+	response, err := client.Get(server.URL, http.Header{})
+	require.NoError(t, err)
+	require.Len(t, recorder.HAR().Log.Entries, 1)
+	assert.Equal(t, float64(0), recorder.HAR().Log.Entries[0].Timings.Receive, "the body is not read yet")
+
+	assert.Equal(t, "slow body", heimdalltest.ResponseBody(t, response))
+	entry := recorder.HAR().Log.Entries[0]
+	assert.True(t, entry.Timings.Receive >= 20, "receive should cover reading the body, got %vms", entry.Timings.Receive)
+	assert.True(t, entry.Time >= entry.Timings.Receive)
+}
+
+func TestHARRecorderFlush(t *testing.T) {
+	recorder := NewHARRecorder(WithHARMaxEntries(1))
+	client := httpclient.NewClient(httpclient.WithHTTPTimeout(10 * time.Millisecond))
+	client.AddPlugin(recorder)
+
+	_, err := client.Get("url_doesnt_exist", http.Header{})
+	require.Error(t, err)
+	_, err = client.Get("url_doesnt_exist_either", http.Header{})
+	require.Error(t, err)
+
+	out := &bytes.Buffer{}
+	require.NoError(t, recorder.Flush(out))
+
+	har := HAR{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &har))
+	require.Len(t, har.Log.Entries, 1)
+	assert.Equal(t, "url_doesnt_exist_either", har.Log.Entries[0].Request.URL)
+	assert.Contains(t, har.Log.Entries[0].Error, "unsupported protocol scheme")
+
+	assert.Empty(t, recorder.HAR().Log.Entries)
+}
@@ -6,19 +6,22 @@ import (
 	"github.com/go-light/httpclient/v3/heimdall"
 	"io"
 	"net/http"
+	"net/http/httptrace"
 	"os"
 	"time"
 )
 
 type ctxKey string
 
-const reqTime ctxKey = "request_time_start"
+const connTimingKey ctxKey = "conn_timing"
 
 type requestLogger struct {
 	out    io.Writer
 	errOut io.Writer
 }
 
+var _ heimdall.ContextPlugin = (*requestLogger)(nil)
+
 // NewRequestLogger returns a new instance of a Heimdall request logger plugin
 // out and errOut are the streams where standard and error logs are written respectively
 // If given as nil, `out` takes the default value of `os.StdOut`
@@ -36,35 +39,58 @@ func NewRequestLogger(out io.Writer, errOut io.Writer) heimdall.Plugin {
 	}
 }
 
-func (rl *requestLogger) OnRequestStart(req *http.Request) {
-	ctx := context.WithValue(req.Context(), reqTime, time.Now())
-	*req = *(req.WithContext(ctx))
+// AttemptContext attaches an httptrace.ClientTrace recording the connection
+// timings of the attempt
+func (rl *requestLogger) AttemptContext(ctx context.Context) context.Context {
+	attempt, _ := heimdall.AttemptFromContext(ctx)
+	recorder := &heimdall.ConnRecorder{Clock: attempt.Clock}
+	ctx = context.WithValue(ctx, connTimingKey, recorder)
+	return httptrace.WithClientTrace(ctx, recorder.ClientTrace())
 }
 
+func (rl *requestLogger) OnRequestStart(req *http.Request) {}
+
 func (rl *requestLogger) OnRequestEnd(req *http.Request, res *http.Response) {
-	reqDurationMs := getRequestDuration(req.Context()) / time.Millisecond
 	method := req.Method
 	url := req.URL.String()
 	statusCode := res.StatusCode
-	fmt.Fprintf(rl.out, "%s %s %s %d [%dms]\n", time.Now().Format("02/Jan/2006 03:04:05"), method, url, statusCode, reqDurationMs)
+	fmt.Fprintf(rl.out, "%s %s %s %d %s\n", time.Now().Format("02/Jan/2006 03:04:05"), method, url, statusCode, formatTimings(req.Context()))
 }
 
 func (rl *requestLogger) OnError(req *http.Request, err error) {
-	reqDurationMs := getRequestDuration(req.Context()) / time.Millisecond
 	method := req.Method
 	url := req.URL.String()
-	fmt.Fprintf(rl.errOut, "%s %s %s [%dms] ERROR: %v\n", time.Now().Format("02/Jan/2006 03:04:05"), method, url, reqDurationMs, err)
+	fmt.Fprintf(rl.errOut, "%s %s %s %s ERROR: %v\n", time.Now().Format("02/Jan/2006 03:04:05"), method, url, formatTimings(req.Context()), err)
 }
 
-func getRequestDuration(ctx context.Context) time.Duration {
-	now := time.Now()
-	start := ctx.Value(reqTime)
-	if start == nil {
-		return 0
+// formatTimings renders the attempt, total and connection timings known for ctx,
+// e.g. "[12ms] attempt=1 total=40ms dns=1ms connect=2ms tls=5ms ttfb=10ms"
+func formatTimings(ctx context.Context) string {
+	var attemptDuration, totalDuration time.Duration
+	attemptNumber := 0
+	if attempt, ok := heimdall.AttemptFromContext(ctx); ok {
+		now := attempt.Now()
+		attemptNumber = attempt.Number
+		attemptDuration = now.Sub(attempt.Start)
+		totalDuration = now.Sub(attempt.RequestStart)
+	}
+
+	var dns, connect, tlsHandshake, ttfb time.Duration
+	if recorder, ok := ctx.Value(connTimingKey).(*heimdall.ConnRecorder); ok {
+		dns, connect, tlsHandshake, ttfb = durations(ctx, recorder.Events())
 	}
-	startTime, ok := start.(time.Time)
-	if !ok {
-		return 0
+
+	return fmt.Sprintf("[%dms] attempt=%d total=%dms dns=%dms connect=%dms tls=%dms ttfb=%dms",
+		attemptDuration/time.Millisecond, attemptNumber, totalDuration/time.Millisecond,
+		dns/time.Millisecond, connect/time.Millisecond, tlsHandshake/time.Millisecond, ttfb/time.Millisecond)
+}
+
+// durations returns the DNS lookup, connect, TLS handshake and time to first
+// byte of the attempt, time to first byte being measured from the attempt start
+func durations(ctx context.Context, events heimdall.ConnEvents) (dns, connect, tlsHandshake, ttfb time.Duration) {
+	var start time.Time
+	if attempt, ok := heimdall.AttemptFromContext(ctx); ok {
+		start = attempt.Start
 	}
-	return now.Sub(startTime)
+	return events.DNSLookup(), events.Connect(), events.TLSHandshake(), events.TimeToFirstByte(start)
 }
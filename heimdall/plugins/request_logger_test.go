@@ -0,0 +1,111 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+	"github.com/go-light/httpclient/v3/heimdall/heimdalltest"
+	"github.com/go-light/httpclient/v3/heimdall/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestLoggerLogsEveryAttempt(t *testing.T) {
+	out := &bytes.Buffer{}
+	client := httpclient.NewClient(
+		httpclient.WithHTTPTimeout(100*time.Millisecond),
+		httpclient.WithRetryCount(2),
+		httpclient.WithRetrier(heimdall.NewRetrier(heimdall.NewConstantBackoff(1*time.Millisecond, 0))),
+	)
+	client.AddPlugin(NewRequestLogger(out, &bytes.Buffer{}))
+
+	dummyHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(dummyHandler))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	ctx := req.Context()
+
+	_, err = client.Do(req)
+	require.NoError(t, err)
+
+	assert.Equal(t, ctx, req.Context(), "the caller's request must not be modified")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	for i, line := range lines {
+		assert.Contains(t, line, "GET "+server.URL+" 500")
+		assert.Contains(t, line, fmt.Sprintf("attempt=%d ", i))
+		assert.Contains(t, line, "ttfb=")
+		assert.Contains(t, line, "connect=")
+	}
+}
+
+func TestRequestLoggerTimesAttemptsWithTheClientClock(t *testing.T) {
+	out := &bytes.Buffer{}
+	clock := heimdalltest.NewFakeClock(time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC))
+	client := httpclient.NewClient(
+		httpclient.WithRetryCount(1),
+		httpclient.WithRetrier(heimdall.NewRetrier(heimdall.NewConstantBackoff(time.Second, 0))),
+		httpclient.WithClock(clock),
+		httpclient.WithSleeper(clock),
+	)
+	client.AddPlugin(NewRequestLogger(out, &bytes.Buffer{}))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	response, err := client.Get(server.URL, http.Header{})
+	require.NoError(t, err)
+	response.Body.Close()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "[0ms] attempt=0 total=0ms dns=0ms connect=0ms tls=0ms ttfb=0ms")
+	assert.Contains(t, lines[1], "[0ms] attempt=1 total=1000ms dns=0ms connect=0ms tls=0ms ttfb=0ms")
+}
+
+func TestRequestLoggerLogsErrors(t *testing.T) {
+	errOut := &bytes.Buffer{}
+	client := httpclient.NewClient(httpclient.WithHTTPTimeout(10 * time.Millisecond))
+	client.AddPlugin(NewRequestLogger(&bytes.Buffer{}, errOut))
+
+	_, err := client.Get("url_doesnt_exist", http.Header{})
+	require.Error(t, err)
+
+	assert.Contains(t, errOut.String(), "GET url_doesnt_exist [")
+	assert.Contains(t, errOut.String(), "attempt=0 total=")
+	assert.Contains(t, errOut.String(), "ERROR: ")
+}
+
+func TestConnTimingDurations(t *testing.T) {
+	start := time.Now()
+	events := heimdall.ConnEvents{
+		GetConn:      start.Add(1 * time.Millisecond),
+		DNSStart:     start,
+		DNSDone:      start.Add(1 * time.Millisecond),
+		ConnectStart: start.Add(1 * time.Millisecond),
+		ConnectDone:  start.Add(3 * time.Millisecond),
+		FirstByte:    start.Add(10 * time.Millisecond),
+	}
+	ctx := heimdall.WithAttempt(context.Background(), heimdall.Attempt{RequestStart: start, Start: start})
+
+	dns, connect, tlsHandshake, ttfb := durations(ctx, events)
+	assert.Equal(t, 1*time.Millisecond, dns)
+	assert.Equal(t, 2*time.Millisecond, connect)
+	assert.Equal(t, time.Duration(0), tlsHandshake)
+	assert.Equal(t, 10*time.Millisecond, ttfb)
+}
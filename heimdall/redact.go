@@ -0,0 +1,55 @@
+package heimdall
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Redacted replaces the values of redacted headers in dumps and recordings
+const Redacted = "[REDACTED]"
+
+// redactedURLPart replaces the user info and secret query values of URLs, the
+// brackets of Redacted would be escaped there
+const redactedURLPart = "REDACTED"
+
+var redactedQueryParams = map[string]bool{
+	"access_token":  true,
+	"api_key":       true,
+	"apikey":        true,
+	"client_secret": true,
+	"password":      true,
+	"secret":        true,
+	"signature":     true,
+	"token":         true,
+}
+
+// RedactedHeaders returns the headers carrying credentials, whose values plugins
+// and recorders replace by Redacted by default
+func RedactedHeaders() []string {
+	return []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+}
+
+// RedactURL returns a copy of u without its credentials: the user info and the
+// values of query parameters such as token or api_key are replaced
+func RedactURL(u *url.URL) *url.URL {
+	redacted := *u
+	if redacted.User != nil {
+		redacted.User = url.User(redactedURLPart)
+	}
+
+	query := redacted.Query()
+	changed := false
+	for name, values := range query {
+		if !redactedQueryParams[strings.ToLower(name)] {
+			continue
+		}
+		for i := range values {
+			values[i] = redactedURLPart
+		}
+		changed = true
+	}
+	if changed {
+		redacted.RawQuery = query.Encode()
+	}
+	return &redacted
+}
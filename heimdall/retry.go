@@ -1,6 +1,9 @@
 package heimdall
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // Retriable defines contract for retriers to implement
 type Retriable interface {
@@ -16,6 +19,20 @@ func (f RetriableFunc) NextInterval(retry int) time.Duration {
 	return f(retry)
 }
 
+type retryCountCtxKey struct{}
+
+// WithRetryCount returns a copy of ctx making the clients retry a request sent
+// with it retryCount times, whatever their own retry count
+func WithRetryCount(ctx context.Context, retryCount int) context.Context {
+	return context.WithValue(ctx, retryCountCtxKey{}, retryCount)
+}
+
+// RetryCountFromContext returns the retry count stored in ctx, if any
+func RetryCountFromContext(ctx context.Context) (int, bool) {
+	retryCount, ok := ctx.Value(retryCountCtxKey{}).(int)
+	return retryCount, ok
+}
+
 type retrier struct {
 	backoff Backoff
 }
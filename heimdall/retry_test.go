@@ -1,6 +1,8 @@
 package heimdall
 
 import (
+	"context"
+	"math/rand"
 	"testing"
 	"time"
 
@@ -9,20 +11,24 @@ import (
 
 func TestRetrierWithExponentialBackoff(t *testing.T) {
 
-	exponentialBackoff := NewExponentialBackoff(2*time.Millisecond, 10*time.Millisecond, 2.0, 1*time.Millisecond)
+	exponentialBackoff := NewExponentialBackoff(2*time.Millisecond, 10*time.Millisecond, 2.0, 1*time.Millisecond, WithRandSource(rand.NewSource(1)))
 	exponentialRetrier := NewRetrier(exponentialBackoff)
 
-	assert.True(t, 4*time.Millisecond <= exponentialRetrier.NextInterval(1))
+	assert.Equal(t, 4*time.Millisecond, exponentialRetrier.NextInterval(1))
+	assert.Equal(t, 9*time.Millisecond, exponentialRetrier.NextInterval(2))
+	assert.Equal(t, 11*time.Millisecond, exponentialRetrier.NextInterval(3))
 }
 
 func TestRetrierWithConstantBackoff(t *testing.T) {
 	backoffInterval := 2 * time.Millisecond
 	maximumJitterInterval := 1 * time.Millisecond
 
-	constantBackoff := NewConstantBackoff(backoffInterval, maximumJitterInterval)
+	constantBackoff := NewConstantBackoff(backoffInterval, maximumJitterInterval, WithRandSource(rand.NewSource(1)))
 	constantRetrier := NewRetrier(constantBackoff)
 
-	assert.True(t, 2*time.Millisecond <= constantRetrier.NextInterval(1))
+	assert.Equal(t, 2*time.Millisecond, constantRetrier.NextInterval(1))
+	assert.Equal(t, 3*time.Millisecond, constantRetrier.NextInterval(2))
+	assert.Equal(t, 3*time.Millisecond, constantRetrier.NextInterval(3))
 }
 
 func TestRetrierFunc(t *testing.T) {
@@ -33,7 +39,7 @@ func TestRetrierFunc(t *testing.T) {
 		return time.Duration(retry) * time.Millisecond
 	})
 
-	assert.True(t, 3*time.Millisecond <= linearRetrier.NextInterval(4))
+	assert.Equal(t, 4*time.Millisecond, linearRetrier.NextInterval(4))
 }
 
 func TestNoRetrier(t *testing.T) {
@@ -41,3 +47,12 @@ func TestNoRetrier(t *testing.T) {
 	nextInterval := noRetrier.NextInterval(1)
 	assert.Equal(t, time.Duration(0), nextInterval)
 }
+
+func TestRetryCountFromContext(t *testing.T) {
+	_, ok := RetryCountFromContext(context.Background())
+	assert.False(t, ok)
+
+	retryCount, ok := RetryCountFromContext(WithRetryCount(context.Background(), 3))
+	assert.True(t, ok)
+	assert.Equal(t, 3, retryCount)
+}
@@ -1,6 +1,16 @@
 package httpclient
 
-import "time"
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+	"github.com/go-light/httpclient/v3/heimdall/breaker"
+	"github.com/go-light/httpclient/v3/heimdall/cache"
+	"github.com/go-light/httpclient/v3/heimdall/coalesce"
+	"github.com/go-light/httpclient/v3/heimdall/fault"
+	"github.com/go-light/httpclient/v3/heimdall/limiter"
+)
 
 // Option represents the client options
 type Option interface {
@@ -41,3 +51,129 @@ func WithMaxIdleConnsPerHost(maxIdleConnsPerHost int) Option {
 		c.maxIdleConnsPerHost = maxIdleConnsPerHost
 	})
 }
+
+// WithHTTPClient sets the Doer sending the requests in place of the default
+// http.Client, e.g. a heimdalltest.Doer in tests. The connection pool options
+// are then ignored
+func WithHTTPClient(client heimdall.Doer) Option {
+	return OptionFunc(func(c *Client) {
+		c.httpClient = client
+	})
+}
+
+// WithConnTrace enables collecting connection diagnostics (DNS, connect and TLS
+// times, connection reuse, remote address and time to first byte) into Resp.ConnTrace
+func WithConnTrace() Option {
+	return OptionFunc(func(c *Client) {
+		c.connTrace = true
+	})
+}
+
+// WithCircuitBreaker guards every request of the client with one circuit breaker
+// created with opts. Rejected requests get a *CircuitOpenError as Resp.Error
+func WithCircuitBreaker(opts ...breaker.Option) Option {
+	return OptionFunc(func(c *Client) {
+		c.middlewares = append(c.middlewares, breaker.New(opts...).Wrap)
+	})
+}
+
+// WithCircuitBreakerPerHost guards the requests of the client with one circuit
+// breaker per host, each created with opts
+func WithCircuitBreakerPerHost(opts ...breaker.Option) Option {
+	return OptionFunc(func(c *Client) {
+		c.middlewares = append(c.middlewares, breaker.NewGroup(breaker.WithBreakerOptions(opts...)).Wrap)
+	})
+}
+
+// WithFallback sets a function called once the request failed, retries
+// included, or got a 5xx response. The response it returns, if any, is used
+// instead and Resp.Fallback is set
+func WithFallback(fallback heimdall.FallbackFunc) Option {
+	return OptionFunc(func(c *Client) {
+		c.fallback = fallback
+	})
+}
+
+// WithStaleCache keeps the last successful response of every GET request and
+// serves it, with Resp.Stale set, when a request fails or the circuit is open,
+// see cache.NewStale
+func WithStaleCache(opts ...cache.StaleOption) Option {
+	return OptionFunc(func(c *Client) {
+		c.staleCache = cache.NewStale(opts...)
+	})
+}
+
+// WithHTTPCache caches the responses of GET requests as RFC 7234 describes,
+// see cache.NewHTTPCache
+func WithHTTPCache(opts ...cache.HTTPCacheOption) Option {
+	return OptionFunc(func(c *Client) {
+		c.middlewares = append(c.middlewares, cache.NewHTTPCache(opts...).Wrap)
+	})
+}
+
+// WithCoalescing makes identical GET requests in flight at the same time share
+// a single upstream call, see coalesce.New
+func WithCoalescing(opts ...coalesce.Option) Option {
+	return OptionFunc(func(c *Client) {
+		c.middlewares = append(c.middlewares, coalesce.New(opts...).Wrap)
+	})
+}
+
+// WithFaultInjector injects the faults of injector into the requests of the
+// client while it is enabled, see fault.NewInjector
+func WithFaultInjector(injector *fault.Injector) Option {
+	return OptionFunc(func(c *Client) {
+		c.middlewares = append(c.middlewares, injector.Wrap)
+	})
+}
+
+// WithBulkhead limits how many requests of the client are in flight at once,
+// in total and per host, see limiter.NewBulkhead
+func WithBulkhead(opts ...limiter.BulkheadOption) Option {
+	return OptionFunc(func(c *Client) {
+		c.middlewares = append(c.middlewares, limiter.NewBulkhead(opts...).Wrap)
+	})
+}
+
+// WithAdaptiveLimiter limits how many requests of the client are in flight at
+// once, the limit adapting to the latency and errors observed by l, see
+// limiter.NewAdaptiveLimiter. l.Limit() exposes the current limit
+func WithAdaptiveLimiter(l *limiter.AdaptiveLimiter) Option {
+	return OptionFunc(func(c *Client) {
+		c.middlewares = append(c.middlewares, l.Wrap)
+	})
+}
+
+// WithRateLimit limits the client to rate requests per second on average with
+// bursts of up to burst requests, see limiter.NewRateLimiter
+func WithRateLimit(rate float64, burst int, opts ...limiter.RateLimitOption) Option {
+	return OptionFunc(func(c *Client) {
+		c.middlewares = append(c.middlewares, limiter.NewRateLimiter(rate, burst, opts...).Wrap)
+	})
+}
+
+// WithBaseURL resolves relative request URLs against baseURL, its path being
+// joined with theirs. Requests to other hosts then fail with
+// heimdall.ErrHostNotAllowed unless allowed WithAllowedHosts, redirects
+// included. A custom http client should use heimdall.CheckRedirect for that
+func WithBaseURL(baseURL string) Option {
+	return OptionFunc(func(c *Client) {
+		c.defaults.BaseURL = baseURL
+	})
+}
+
+// WithDefaultHeaders sets headers, e.g. User-Agent or an API key, sent with
+// every request which doesn't set them
+func WithDefaultHeaders(headers http.Header) Option {
+	return OptionFunc(func(c *Client) {
+		c.defaults.Header = headers.Clone()
+	})
+}
+
+// WithAllowedHosts restricts requests to the given hosts, on top of the one of
+// the base URL, failing the others with heimdall.ErrHostNotAllowed
+func WithAllowedHosts(hosts ...string) Option {
+	return OptionFunc(func(c *Client) {
+		c.defaults.AllowedHosts = append(c.defaults.AllowedHosts, hosts...)
+	})
+}
@@ -0,0 +1,445 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+	"github.com/go-light/logentry"
+	"github.com/pkg/errors"
+)
+
+// ErrPaginationStuck is returned by an Iterator when the next page would be
+// the one just fetched, which would never end
+var ErrPaginationStuck = errors.New("pagination did not advance")
+
+// Pagination tells an Iterator which page to fetch next
+type Pagination interface {
+	// First returns the URL of the first page, given the URL passed to Paginate
+	First(url string) (string, error)
+	// Next returns the URL of the page following the one fetched from url,
+	// which held the given number of items, or "" when it was the last one
+	Next(url string, resp *Resp, items int) (string, error)
+}
+
+type linkHeader struct{}
+
+// LinkHeader follows the rel="next" URL of the RFC 8288 Link response header,
+// as GitHub does
+func LinkHeader() Pagination {
+	return linkHeader{}
+}
+
+func (linkHeader) First(url string) (string, error) {
+	return url, nil
+}
+
+func (linkHeader) Next(current string, resp *Resp, items int) (string, error) {
+	next := nextLink(resp.Header)
+	if next == "" {
+		return "", nil
+	}
+
+	base, err := url.Parse(current)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(next)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid Link header")
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+// nextLink returns the rel="next" URL of the Link headers, if any
+func nextLink(header http.Header) string {
+	for _, value := range header.Values("Link") {
+		for _, link := range splitUnquoted(value, ',') {
+			parts := splitUnquoted(link, ';')
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+
+			for _, param := range parts[1:] {
+				name, rel, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(name), "rel") {
+					continue
+				}
+				for _, r := range strings.Fields(strings.Trim(strings.TrimSpace(rel), `"`)) {
+					if strings.EqualFold(r, "next") {
+						return target[1 : len(target)-1]
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// splitUnquoted splits s around sep, except inside <URL> and quoted strings
+// which may hold it, e.g. <https://api.example.com/items?ids=1,2>
+func splitUnquoted(s string, sep byte) []string {
+	var parts []string
+	inURL, inQuote, start := false, false, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case inQuote:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inQuote = false
+			}
+		case inURL:
+			inURL = c != '>'
+		case c == '"':
+			inQuote = true
+		case c == '<':
+			inURL = true
+		case c == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+type cursor struct {
+	param string
+	field string
+}
+
+// Cursor sets the query parameter param of the next page to the cursor read
+// from field of the JSON body, a dot separated path such as "meta.next_cursor".
+// Pages end with a missing, null or empty cursor
+func Cursor(param, field string) Pagination {
+	return cursor{param: param, field: field}
+}
+
+func (c cursor) First(url string) (string, error) {
+	return url, nil
+}
+
+func (c cursor) Next(current string, resp *Resp, items int) (string, error) {
+	raw, err := jsonField(resp.Body, c.field)
+	if err != nil {
+		return "", err
+	}
+
+	next, err := jsonScalar(raw)
+	if err != nil || next == "" {
+		return "", err
+	}
+	return setQuery(current, map[string]string{c.param: next})
+}
+
+type offsetLimit struct {
+	offsetParam string
+	limitParam  string
+	limit       int
+}
+
+// OffsetLimit requests pages of limit items, moving the offsetParam query
+// parameter forward by the number of items of every page. Pages end with one
+// holding fewer than limit items
+func OffsetLimit(offsetParam, limitParam string, limit int) Pagination {
+	return offsetLimit{offsetParam: offsetParam, limitParam: limitParam, limit: limit}
+}
+
+func (o offsetLimit) First(current string) (string, error) {
+	u, err := url.Parse(current)
+	if err != nil {
+		return "", err
+	}
+
+	params := map[string]string{o.limitParam: strconv.Itoa(o.limit)}
+	if u.Query().Get(o.offsetParam) == "" {
+		params[o.offsetParam] = "0"
+	}
+	return setQuery(current, params)
+}
+
+func (o offsetLimit) Next(current string, resp *Resp, items int) (string, error) {
+	if items < o.limit || items == 0 {
+		return "", nil
+	}
+
+	u, err := url.Parse(current)
+	if err != nil {
+		return "", err
+	}
+
+	offset := 0
+	if value := u.Query().Get(o.offsetParam); value != "" {
+		if offset, err = strconv.Atoi(value); err != nil {
+			return "", errors.Wrapf(err, "invalid %s", o.offsetParam)
+		}
+	}
+	return setQuery(current, map[string]string{o.offsetParam: strconv.Itoa(offset + items)})
+}
+
+// PaginateOption represents the options of Paginate
+type PaginateOption func(*paginateOptions)
+
+type paginateOptions struct {
+	headers    http.Header
+	itemsField string
+	maxPages   int
+	onPage     func(resp *Resp)
+}
+
+// WithPageHeaders sets the headers sent with every page request
+func WithPageHeaders(headers http.Header) PaginateOption {
+	return func(o *paginateOptions) {
+		o.headers = headers
+	}
+}
+
+// WithItemsField sets the field of the JSON body holding the items of a page,
+// a dot separated path such as "data.items". By default the body is the list of items
+func WithItemsField(field string) PaginateOption {
+	return func(o *paginateOptions) {
+		o.itemsField = field
+	}
+}
+
+// WithMaxPages stops the iteration after maxPages pages
+func WithMaxPages(maxPages int) PaginateOption {
+	return func(o *paginateOptions) {
+		o.maxPages = maxPages
+	}
+}
+
+// WithOnPage calls fn with the Resp of every page fetched, e.g. to log its LogEntry
+func WithOnPage(fn func(resp *Resp)) PaginateOption {
+	return func(o *paginateOptions) {
+		o.onPage = fn
+	}
+}
+
+// Iterator yields the items of a paginated resource, fetching pages as they
+// are needed. It is not safe for concurrent use
+//
+//	it := httpclient.Paginate[User](ctx, client, url, httpclient.LinkHeader(), httpclient.WithMaxPages(10))
+//	for it.Next() {
+//		user := it.Item()
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type Iterator[T any] struct {
+	ctx        context.Context
+	client     HttpClient
+	pagination Pagination
+	opts       paginateOptions
+
+	origin string // URL of the first page
+	url    string // of the next page, "" once the last one was fetched
+	items  []T
+	index  int
+	item   T
+
+	resp       *Resp
+	pages      int
+	logEntries []logentry.HttpClientLogEntry
+	err        error
+}
+
+// Paginate returns an iterator over the items of the pages found from url by
+// pagination. Pages on another scheme or host than url fail with
+// heimdall.ErrHostNotAllowed, so the page headers are never sent elsewhere
+func Paginate[T any](ctx context.Context, client HttpClient, url string, pagination Pagination, opts ...PaginateOption) *Iterator[T] {
+	it := &Iterator[T]{
+		ctx:        ctx,
+		client:     client,
+		pagination: pagination,
+	}
+	for _, opt := range opts {
+		opt(&it.opts)
+	}
+
+	it.url, it.err = pagination.First(url)
+	it.origin = it.url
+	return it
+}
+
+// Next advances to the next item, fetching the next page when needed. It
+// returns false once the items are exhausted or an error occurred
+func (it *Iterator[T]) Next() bool {
+	for it.err == nil {
+		if it.index < len(it.items) {
+			it.item = it.items[it.index]
+			it.index++
+			return true
+		}
+
+		if it.url == "" || (it.opts.maxPages > 0 && it.pages >= it.opts.maxPages) {
+			return false
+		}
+		if err := it.ctx.Err(); err != nil {
+			it.err = err
+			return false
+		}
+		it.err = it.fetch()
+	}
+	return false
+}
+
+// fetch gets the page at it.url and finds the URL of the following one
+func (it *Iterator[T]) fetch() error {
+	current := it.url
+	if !sameOrigin(it.origin, current) {
+		return errors.Wrapf(heimdall.ErrHostNotAllowed, "page %d - %s", it.pages+1, current)
+	}
+
+	resp := it.client.Get(it.ctx, current, it.opts.headers, nil)
+	it.resp = resp
+	it.pages++
+	it.logEntries = append(it.logEntries, resp.LogEntry)
+	if it.opts.onPage != nil {
+		it.opts.onPage(resp)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	items, err := decodeItems[T](resp.Body, it.opts.itemsField)
+	if err != nil {
+		return errors.Wrapf(err, "page %d - decoding failed", it.pages)
+	}
+	it.items = items
+	it.index = 0
+
+	next, err := it.pagination.Next(current, resp, len(items))
+	if err != nil {
+		return errors.Wrapf(err, "page %d - pagination failed", it.pages)
+	}
+	if next == current {
+		return errors.Wrapf(ErrPaginationStuck, "page %d", it.pages)
+	}
+	it.url = next
+	return nil
+}
+
+// Item returns the current item
+func (it *Iterator[T]) Item() T {
+	return it.item
+}
+
+// Err returns the error which stopped the iteration, if any
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// Resp returns the Resp of the last page fetched, nil before the first one
+func (it *Iterator[T]) Resp() *Resp {
+	return it.resp
+}
+
+// Pages returns the number of pages fetched
+func (it *Iterator[T]) Pages() int {
+	return it.pages
+}
+
+// LogEntries returns the log entries of the pages fetched, in order
+func (it *Iterator[T]) LogEntries() []logentry.HttpClientLogEntry {
+	return it.logEntries
+}
+
+// decodeItems decodes the list of items held by field of the JSON body
+func decodeItems[T any](body []byte, field string) ([]T, error) {
+	raw, err := jsonField(body, field)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []T
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return items, nil
+	}
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// jsonField returns the raw value of the dot separated path of the JSON body,
+// the body itself for an empty path and nil for a missing field
+func jsonField(body []byte, path string) (json.RawMessage, error) {
+	raw := json.RawMessage(body)
+	if path == "" {
+		return raw, nil
+	}
+
+	for _, name := range strings.Split(path, ".") {
+		if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+			return nil, nil
+		}
+
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &object); err != nil {
+			return nil, errors.Wrapf(err, "field %s", path)
+		}
+		raw = object[name]
+	}
+	return raw, nil
+}
+
+// jsonScalar returns a JSON string or number as a string, "" for null
+func jsonScalar(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return "", nil
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return "", err
+	}
+
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	default:
+		return "", fmt.Errorf("cursor %s is neither a string nor a number", raw)
+	}
+}
+
+// sameOrigin tells whether page has the scheme and host of origin, so page
+// headers and credentials are never sent elsewhere. A relative origin is
+// resolved by the client, which restricts the hosts itself WithBaseURL
+func sameOrigin(origin, page string) bool {
+	o, err := url.Parse(origin)
+	if err != nil || o.Host == "" {
+		return true
+	}
+	p, err := url.Parse(page)
+	if err != nil {
+		return false
+	}
+	return p.Host == "" || (strings.EqualFold(o.Scheme, p.Scheme) && strings.EqualFold(o.Host, p.Host))
+}
+
+// setQuery returns rawURL with the given query parameters set
+func setQuery(rawURL string, params map[string]string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
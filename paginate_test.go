@@ -0,0 +1,204 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func collect[T any](it *Iterator[T]) []T {
+	var items []T
+	for it.Next() {
+		items = append(items, it.Item())
+	}
+	return items
+}
+
+func TestPaginateWithLinkHeader(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 2 {
+			w.Header().Add("Link", fmt.Sprintf(`<%s/users?page=%d>; rel="next", <%s/users?page=2>; rel="last"`, server.URL, page+1, server.URL))
+		}
+		fmt.Fprintf(w, `[{"id":%d},{"id":%d}]`, page*2, page*2+1)
+	}))
+	defer server.Close()
+
+	var pages []*Resp
+	it := Paginate[testUser](context.Background(), NewClientV3(), server.URL+"/users?page=0", LinkHeader(),
+		WithOnPage(func(resp *Resp) { pages = append(pages, resp) }),
+	)
+	users := collect(it)
+	require.NoError(t, it.Err())
+
+	require.Len(t, users, 6)
+	for i, user := range users {
+		assert.Equal(t, i, user.ID)
+	}
+	assert.Equal(t, 3, it.Pages())
+	assert.Len(t, pages, 3)
+	require.Len(t, it.LogEntries(), 3)
+	assert.Contains(t, it.LogEntries()[2].Text(), "page=2")
+}
+
+func TestPaginateWithRelativeLinkHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", `</users?page=1>; rel="next"`)
+		}
+		w.Write([]byte(`[{"id":1}]`))
+	}))
+	defer server.Close()
+
+	it := Paginate[testUser](context.Background(), NewClientV3(), server.URL+"/users", LinkHeader())
+	assert.Len(t, collect(it), 2)
+	assert.NoError(t, it.Err())
+}
+
+func TestPaginateWithCursor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			w.Write([]byte(`{"data":[{"id":1},{"id":2}],"meta":{"next":"abc"}}`))
+		case "abc":
+			w.Write([]byte(`{"data":[{"id":3}],"meta":{"next":42}}`))
+		case "42":
+			w.Write([]byte(`{"data":[{"id":4}],"meta":{"next":null}}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	it := Paginate[testUser](context.Background(), NewClientV3(), server.URL, Cursor("cursor", "meta.next"), WithItemsField("data"))
+	users := collect(it)
+	require.NoError(t, it.Err())
+
+	assert.Equal(t, []testUser{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}, users)
+	assert.Equal(t, 3, it.Pages())
+}
+
+func TestPaginateWithOffsetLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		assert.Equal(t, 2, limit)
+
+		var users []testUser
+		for id := offset; id < offset+limit && id < 5; id++ {
+			users = append(users, testUser{ID: id})
+		}
+		json.NewEncoder(w).Encode(users)
+	}))
+	defer server.Close()
+
+	it := Paginate[testUser](context.Background(), NewClientV3(), server.URL, OffsetLimit("offset", "limit", 2))
+	users := collect(it)
+	require.NoError(t, it.Err())
+
+	assert.Equal(t, []testUser{{ID: 0}, {ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}, users)
+	assert.Equal(t, 3, it.Pages())
+}
+
+func TestPaginateWithMaxPages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id":1},{"id":2}]`))
+	}))
+	defer server.Close()
+
+	it := Paginate[testUser](context.Background(), NewClientV3(), server.URL, OffsetLimit("offset", "limit", 2), WithMaxPages(2))
+	assert.Len(t, collect(it), 4)
+	assert.NoError(t, it.Err())
+	assert.Equal(t, 2, it.Pages())
+}
+
+func TestPaginateStopsWhenContextIsDone(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id":1}]`))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	it := Paginate[testUser](ctx, NewClientV3(), server.URL, OffsetLimit("offset", "limit", 1))
+
+	require.True(t, it.Next())
+	cancel()
+
+	assert.False(t, it.Next())
+	assert.True(t, errors.Is(it.Err(), context.Canceled))
+	assert.Equal(t, 1, it.Pages())
+}
+
+func TestPaginateStopsOnPageError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("offset") != "0" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`[{"id":1}]`))
+	}))
+	defer server.Close()
+
+	it := Paginate[testUser](context.Background(), NewClientV3(), server.URL, OffsetLimit("offset", "limit", 1))
+	assert.Len(t, collect(it), 1)
+	require.Error(t, it.Err())
+	assert.Equal(t, http.StatusBadRequest, it.Resp().StatusCode)
+}
+
+func TestPaginateFailsWhenCursorDoesNotAdvance(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"items":[{"id":1}],"next":"same"}`))
+	}))
+	defer server.Close()
+
+	it := Paginate[testUser](context.Background(), NewClientV3(), server.URL, Cursor("cursor", "next"), WithItemsField("items"))
+	assert.Len(t, collect(it), 1)
+	assert.True(t, errors.Is(it.Err(), ErrPaginationStuck))
+}
+
+func TestPaginateRefusesNextPagesOnOtherHosts(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("the page headers were sent to %s", r.Host)
+	}))
+	defer other.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", fmt.Sprintf(`<%s/users?page=1>; rel="next"`, other.URL))
+		w.Write([]byte(`[{"id":1}]`))
+	}))
+	defer server.Close()
+
+	it := Paginate[testUser](context.Background(), NewClientV3(), server.URL+"/users", LinkHeader(),
+		WithPageHeaders(http.Header{"Authorization": {"Bearer secret"}}),
+	)
+	assert.Len(t, collect(it), 1)
+	assert.True(t, errors.Is(it.Err(), heimdall.ErrHostNotAllowed))
+	assert.Equal(t, 1, it.Pages())
+}
+
+func TestNextLink(t *testing.T) {
+	header := http.Header{}
+	header.Add("Link", `<https://api.example.com/items?page=1>; rel="prev first"`)
+	header.Add("Link", `<https://api.example.com/items?page=3>; title="next page"; REL=next`)
+
+	assert.Equal(t, "https://api.example.com/items?page=3", nextLink(header))
+	assert.Equal(t, "", nextLink(http.Header{}))
+}
+
+func TestNextLinkWithCommas(t *testing.T) {
+	header := http.Header{}
+	header.Set("Link", `<https://api.example.com/items?ids=1,2;x&page=1>; rel="prev", `+
+		`<https://api.example.com/items?ids=1,2;x&page=3>; title="page 3, then; more"; rel="next"`)
+
+	assert.Equal(t, "https://api.example.com/items?ids=1,2;x&page=3", nextLink(header))
+}
@@ -0,0 +1,196 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+	"github.com/pkg/errors"
+)
+
+type timeoutCtxKey struct{}
+
+// Request builds a single request made with the client it was created from
+// by Client.R. It is not safe for concurrent use
+//
+//	var user User
+//	var apiErr APIError
+//	ret := client.R(ctx).
+//		SetPathParam("id", id).
+//		SetQuery("fields", "name,email").
+//		SetHeader("Accept-Language", "en").
+//		SetResult(&user).
+//		SetError(&apiErr).
+//		SetTimeout(500 * time.Millisecond).
+//		Get("https://api.example.com/users/{id}")
+type Request struct {
+	client *Client
+	ctx    context.Context
+
+	query      url.Values
+	pathParams map[string]string
+	header     http.Header
+	body       interface{}
+	result     interface{}
+	errResult  interface{}
+
+	timeout    time.Duration
+	retryCount *int
+}
+
+// R returns a new request builder bound to ctx
+func (c *Client) R(ctx context.Context) *Request {
+	return &Request{
+		client:     c,
+		ctx:        ctx,
+		query:      url.Values{},
+		pathParams: map[string]string{},
+		header:     http.Header{},
+	}
+}
+
+// SetQuery adds a query parameter, on top of those the URL already has
+func (r *Request) SetQuery(key, value string) *Request {
+	r.query.Add(key, value)
+	return r
+}
+
+// SetQueryParams adds the given query parameters
+func (r *Request) SetQueryParams(params map[string]string) *Request {
+	for key, value := range params {
+		r.query.Add(key, value)
+	}
+	return r
+}
+
+// SetPathParam replaces the {key} placeholder of the URL path by value, escaped
+func (r *Request) SetPathParam(key, value string) *Request {
+	r.pathParams[key] = value
+	return r
+}
+
+// SetHeader sets a request header, replacing any value it had
+func (r *Request) SetHeader(key, value string) *Request {
+	r.header.Set(key, value)
+	return r
+}
+
+// SetHeaders sets the given request headers
+func (r *Request) SetHeaders(headers map[string]string) *Request {
+	for key, value := range headers {
+		r.header.Set(key, value)
+	}
+	return r
+}
+
+// SetBody sets the request body. An io.Reader, []byte or string is sent as is,
+// any other value is encoded as JSON
+func (r *Request) SetBody(body interface{}) *Request {
+	r.body = body
+	return r
+}
+
+// SetResult sets the value the JSON body of a successful response is decoded into
+func (r *Request) SetResult(result interface{}) *Request {
+	r.result = result
+	return r
+}
+
+// SetError sets the value the JSON body of a 4xx or 5xx response is decoded
+// into. Resp.Error is set regardless
+func (r *Request) SetError(errResult interface{}) *Request {
+	r.errResult = errResult
+	return r
+}
+
+// SetTimeout bounds the whole request, retries and backoffs included. It
+// overrides the client timeout, which then no longer bounds each attempt, so
+// it can be longer. A client set WithHTTPClient keeps its own timeout
+func (r *Request) SetTimeout(timeout time.Duration) *Request {
+	r.timeout = timeout
+	return r
+}
+
+// SetRetryCount overrides the retry count of the client for this request
+func (r *Request) SetRetryCount(retryCount int) *Request {
+	r.retryCount = &retryCount
+	return r
+}
+
+// Get makes a GET request to url
+func (r *Request) Get(url string) *Resp {
+	return r.execute(http.MethodGet, url)
+}
+
+// Post makes a POST request to url
+func (r *Request) Post(url string) *Resp {
+	return r.execute(http.MethodPost, url)
+}
+
+func (r *Request) execute(method, rawURL string) *Resp {
+	requestURL, err := r.url(rawURL)
+	if err != nil {
+		return &Resp{Error: errors.Wrapf(err, "%s - request creation failed", method)}
+	}
+
+	body, err := r.bodyReader()
+	if err != nil {
+		return &Resp{Error: errors.Wrapf(err, "%s - request body encoding failed", method)}
+	}
+
+	ctx := r.ctx
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+		ctx = context.WithValue(ctx, timeoutCtxKey{}, r.timeout)
+	}
+	if r.retryCount != nil {
+		ctx = heimdall.WithRetryCount(ctx, *r.retryCount)
+	}
+
+	ret := r.client.do(ctx, requestURL, method, r.header, body, r.result)
+	if r.errResult != nil && ret.StatusCode >= http.StatusBadRequest && len(ret.Body) > 0 {
+		// the body is kept in ret.Body when it doesn't decode
+		_ = json.Unmarshal(ret.Body, r.errResult)
+	}
+	return ret
+}
+
+// url returns rawURL with its path parameters replaced and the query
+// parameters added
+func (r *Request) url(rawURL string) (string, error) {
+	for key, value := range r.pathParams {
+		rawURL = strings.Replace(rawURL, "{"+key+"}", url.PathEscape(value), -1)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	if len(r.query) > 0 {
+		query := u.Query()
+		for key, values := range r.query {
+			for _, value := range values {
+				query.Add(key, value)
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.String(), nil
+}
+
+func (r *Request) bodyReader() (io.Reader, error) {
+	switch body := r.body.(type) {
+	case nil:
+		return nil, nil
+	default:
+		return requestBody(body)
+	}
+}
@@ -0,0 +1,140 @@
+package httpclient
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequest_Get(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/users/a%2Fb%20c", r.URL.EscapedPath())
+		assert.Equal(t, "name,email", r.URL.Query().Get("fields"))
+		assert.Equal(t, "1", r.URL.Query().Get("v"))
+		assert.Equal(t, "en", r.Header.Get("Accept-Language"))
+		w.Write([]byte(`{"id":1,"name":"jane"}`))
+	}))
+	defer server.Close()
+
+	var user testUser
+	ret := NewClientV3().R(context.Background()).
+		SetPathParam("id", "a/b c").
+		SetQuery("fields", "name,email").
+		SetHeader("Accept-Language", "en").
+		SetResult(&user).
+		Get(server.URL + "/users/{id}?v=1")
+	require.NoError(t, ret.Error)
+
+	assert.Equal(t, testUser{ID: 1, Name: "jane"}, user)
+}
+
+func TestRequest_Post(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.JSONEq(t, `{"id":0,"name":"john"}`, string(body))
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	var user testUser
+	ret := NewClientV3().R(context.Background()).
+		SetBody(testUser{Name: "john"}).
+		SetResult(&user).
+		Post(server.URL)
+	require.NoError(t, ret.Error)
+
+	assert.Equal(t, "john", user.Name)
+}
+
+func TestRequest_SetError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":42,"message":"invalid name"}`))
+	}))
+	defer server.Close()
+
+	var user testUser
+	var apiErr struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	ret := NewClientV3().R(context.Background()).
+		SetResult(&user).
+		SetError(&apiErr).
+		Get(server.URL)
+
+	require.Error(t, ret.Error)
+	assert.Equal(t, http.StatusBadRequest, ret.StatusCode)
+	assert.Equal(t, 42, apiErr.Code)
+	assert.Equal(t, "invalid name", apiErr.Message)
+	assert.Equal(t, testUser{}, user)
+}
+
+func TestRequest_SetRetryCount(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClientV3(WithRetryCount(1))
+
+	ret := client.R(context.Background()).SetRetryCount(3).Get(server.URL)
+	assert.Len(t, ret.Attempts, 4)
+
+	ret = client.R(context.Background()).SetRetryCount(0).Get(server.URL)
+	assert.Len(t, ret.Attempts, 1)
+	assert.Equal(t, int32(5), atomic.LoadInt32(&count))
+}
+
+func TestRequest_SetTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	ret := NewClientV3(WithTimeout(Duration(5 * time.Second))).R(context.Background()).
+		SetTimeout(20 * time.Millisecond).
+		Get(server.URL)
+
+	require.Error(t, ret.Error)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestRequest_SetTimeoutLongerThanTheClientTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"name":"john"}`))
+	}))
+	defer server.Close()
+
+	client := NewClientV3(WithTimeout(Duration(10*time.Millisecond)), WithRetryCount(0))
+
+	ret := client.R(context.Background()).Get(server.URL)
+	require.Error(t, ret.Error, "the client timeout bounds requests without their own")
+
+	var user testUser
+	ret = client.R(context.Background()).
+		SetTimeout(time.Second).
+		SetResult(&user).
+		Get(server.URL)
+	require.NoError(t, ret.Error)
+	assert.Equal(t, "john", user.Name)
+}
+
+func TestRequest_InvalidURL(t *testing.T) {
+	ret := NewClientV3().R(context.Background()).Get("http://[::1")
+	assert.Error(t, ret.Error)
+}
@@ -0,0 +1,49 @@
+package httpclient
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-light/httpclient/v3/heimdall"
+)
+
+// ConnTrace holds the connection diagnostics of the last attempt of a request,
+// collected via net/http/httptrace
+type ConnTrace struct {
+	// DNSLookup is the time spent resolving the host, 0 when no lookup happened
+	DNSLookup time.Duration
+	// Connect is the time spent dialing the remote address
+	Connect time.Duration
+	// TLSHandshake is the time spent in the TLS handshake
+	TLSHandshake time.Duration
+	// FirstByte is the time from asking for a connection to the first response byte
+	FirstByte time.Duration
+	// Reused reports whether the connection was taken from the idle pool
+	Reused bool
+	// WasIdle reports whether a reused connection was idle, and IdleTime for how long
+	WasIdle  bool
+	IdleTime time.Duration
+	// RemoteAddr is the address of the remote end of the connection
+	RemoteAddr string
+}
+
+// Text returns the trace in the key=value format of logentry.HttpClientLogEntry.Text
+func (t *ConnTrace) Text() string {
+	return fmt.Sprintf("dns_ms=%d,connect_ms=%d,tls_ms=%d,ttfb_ms=%d,conn_reused=%t,conn_was_idle=%t,conn_idle_ms=%d,remote_addr=%s",
+		t.DNSLookup.Milliseconds(), t.Connect.Milliseconds(), t.TLSHandshake.Milliseconds(), t.FirstByte.Milliseconds(),
+		t.Reused, t.WasIdle, t.IdleTime.Milliseconds(), t.RemoteAddr)
+}
+
+// newConnTrace returns the trace of the events recorded for a request
+func newConnTrace(events heimdall.ConnEvents) *ConnTrace {
+	return &ConnTrace{
+		DNSLookup:    events.DNSLookup(),
+		Connect:      events.Connect(),
+		TLSHandshake: events.TLSHandshake(),
+		FirstByte:    events.TimeToFirstByte(time.Time{}),
+		Reused:       events.ConnInfo.Reused,
+		WasIdle:      events.ConnInfo.WasIdle,
+		IdleTime:     events.ConnInfo.IdleTime,
+		RemoteAddr:   events.RemoteAddr(),
+	}
+}